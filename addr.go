@@ -0,0 +1,159 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/alrusov/misc"
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+const (
+	// UnixSocketPrefix -- prefix of the unix socket address, for example "unix:/run/app.sock"
+	UnixSocketPrefix = "unix:"
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// ParseBindAddr -- validate host:port or unix:<path> address. Named ports are resolved to numbers
+func ParseBindAddr(addr string) (network string, address string, err error) {
+	addr = strings.TrimSpace(addr)
+
+	if strings.HasPrefix(addr, UnixSocketPrefix) {
+		path := strings.TrimSpace(addr[len(UnixSocketPrefix):])
+		if path == "" {
+			err = fmt.Errorf(`empty unix socket path in "%s"`, addr)
+			return
+		}
+
+		path, err = misc.AbsPath(path)
+		if err != nil {
+			return
+		}
+
+		return "unix", path, nil
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return
+	}
+
+	if port == "" {
+		err = fmt.Errorf(`empty port in "%s"`, addr)
+		return
+	}
+
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		n, err = net.LookupPort("tcp", port)
+		if err != nil {
+			err = fmt.Errorf(`unknown port "%s" in "%s"`, port, addr)
+			return
+		}
+	}
+
+	if n < 0 || n > 65535 {
+		err = fmt.Errorf(`port %d is out of range in "%s"`, n, addr)
+		return
+	}
+
+	return "tcp", net.JoinHostPort(host, strconv.Itoa(n)), nil
+}
+
+// normalizeBindAddr -- the address in the form that is stored in the config
+func normalizeBindAddr(addr string) (string, error) {
+	network, address, err := ParseBindAddr(addr)
+	if err != nil {
+		return addr, err
+	}
+
+	if network == "unix" {
+		return UnixSocketPrefix + address, nil
+	}
+
+	return address, nil
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// checkAddr -- validate Addr and DebugAddr, probe them if required
+func (x *Listener) checkAddr(msgs *misc.Messages) {
+	var err error
+
+	x.Addr, err = normalizeBindAddr(x.bindAddr())
+	if err != nil {
+		msgs.Add("listener.bind-addr: %s", err)
+		return
+	}
+
+	x.DebugAddr = strings.TrimSpace(x.DebugAddr)
+	if x.DebugAddr != "" {
+		x.DebugAddr, err = normalizeBindAddr(x.DebugAddr)
+		if err != nil {
+			msgs.Add("listener.debug-bind-addr: %s", err)
+			return
+		}
+
+		if x.DebugAddr == x.Addr {
+			msgs.Add(`listener.debug-bind-addr: "%s" is the same as bind-addr`, x.DebugAddr)
+			return
+		}
+	}
+
+	if !x.ProbeBind || runtimeCheck.Load() {
+		// the listeners of the running application hold their ports
+		return
+	}
+
+	err = probeBind(x.Addr)
+	if err != nil {
+		msgs.Add("listener.bind-addr: %s", err)
+	}
+
+	if x.DebugAddr != "" {
+		err = probeBind(x.DebugAddr)
+		if err != nil {
+			msgs.Add("listener.debug-bind-addr: %s", err)
+		}
+	}
+}
+
+// probeBind -- try to listen and close immediately
+func probeBind(addr string) error {
+	ln, err := listen(addr)
+	if err != nil {
+		return err
+	}
+
+	return ln.Close()
+}
+
+func listen(addr string) (net.Listener, error) {
+	network, address, err := ParseBindAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return net.Listen(network, address)
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// Network -- "tcp" or "unix"
+func (x *Listener) Network() string {
+	if strings.HasPrefix(x.Addr, UnixSocketPrefix) {
+		return "unix"
+	}
+	return "tcp"
+}
+
+// Listen -- open the listening socket for Addr
+func (x *Listener) Listen() (net.Listener, error) {
+	return listen(x.bindAddr())
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
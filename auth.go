@@ -3,19 +3,31 @@ package config
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/alrusov/misc"
 )
 
 //----------------------------------------------------------------------------------------------------------------------------//
 
-var knownAuthMethods = map[string]*authMethod{}
+var (
+	knownAuthMethodsMutex = new(sync.RWMutex)
+	knownAuthMethods      = map[string]*authMethod{}
+)
 
 type (
 	authMethod struct {
-		options any
-		check   reflect.Value
+		info    AuthMethodInfo
+		options any // prototype, each Auth block gets its own copy
+	}
+
+	// AuthMethodInfo -- registered auth method metadata
+	AuthMethodInfo struct {
+		Name         string `json:"name"`
+		Description  string `json:"description"`
+		DefaultScore int    `json:"defaultScore"` // used if the score is not set in the config
 	}
 )
 
@@ -23,9 +35,14 @@ type (
 
 // AddAuthMethod --
 func AddAuthMethod(name string, options any) (err error) {
-	_, exists := knownAuthMethods[name]
-	if exists {
-		return fmt.Errorf(`method "%s" is already defined`, name)
+	return RegisterAuthMethod(name, options, AuthMethodInfo{})
+}
+
+// RegisterAuthMethod -- AddAuthMethod with metadata
+func RegisterAuthMethod(name string, options any, info AuthMethodInfo) (err error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf(`empty method name`)
 	}
 
 	if options == nil {
@@ -45,13 +62,23 @@ func AddAuthMethod(name string, options any) (err error) {
 	}
 
 	m := vp.MethodByName("Check")
-	if m.Kind() != reflect.Func {
+	if !isCheckMethod(m) {
 		return fmt.Errorf(`"%#v" doesn't have the "Check" method`, options)
 	}
 
+	info.Name = name
+
 	method := &authMethod{
+		info:    info,
 		options: options,
-		check:   m,
+	}
+
+	knownAuthMethodsMutex.Lock()
+	defer knownAuthMethodsMutex.Unlock()
+
+	_, exists := knownAuthMethods[name]
+	if exists {
+		return fmt.Errorf(`method "%s" is already defined`, name)
 	}
 
 	knownAuthMethods[name] = method
@@ -59,6 +86,65 @@ func AddAuthMethod(name string, options any) (err error) {
 	return
 }
 
+// UnregisterAuthMethod --
+func UnregisterAuthMethod(name string) (err error) {
+	knownAuthMethodsMutex.Lock()
+	defer knownAuthMethodsMutex.Unlock()
+
+	_, exists := knownAuthMethods[name]
+	if !exists {
+		return fmt.Errorf(`method "%s" is not defined`, name)
+	}
+
+	delete(knownAuthMethods, name)
+
+	return
+}
+
+// ListAuthMethods -- registered auth methods sorted by name
+func ListAuthMethods() (list []AuthMethodInfo) {
+	knownAuthMethodsMutex.RLock()
+	defer knownAuthMethodsMutex.RUnlock()
+
+	list = make([]AuthMethodInfo, 0, len(knownAuthMethods))
+	for _, m := range knownAuthMethods {
+		list = append(list, m.info)
+	}
+
+	sort.Slice(list,
+		func(i, j int) bool {
+			return list[i].Name < list[j].Name
+		},
+	)
+
+	return
+}
+
+func getAuthMethod(name string) (m *authMethod, exists bool) {
+	knownAuthMethodsMutex.RLock()
+	defer knownAuthMethodsMutex.RUnlock()
+
+	m, exists = knownAuthMethods[name]
+	return
+}
+
+// newOptions -- a fresh copy of the options prototype
+func (m *authMethod) newOptions() any {
+	proto := reflect.ValueOf(m.options)
+	v := reflect.New(proto.Elem().Type())
+	v.Elem().Set(proto.Elem())
+	return v.Interface()
+}
+
+func knownAuthMethodNames() string {
+	list := ListAuthMethods()
+	names := make([]string, len(list))
+	for i, m := range list {
+		names[i] = m.Name
+	}
+	return strings.Join(names, ", ")
+}
+
 //----------------------------------------------------------------------------------------------------------------------------//
 
 // Check --
@@ -68,6 +154,11 @@ func (x *Auth) Check(cfg any) (err error) {
 
 	x.Endpoints = authSlice2Map(x.EndpointsSlice)
 
+	x.endpoints, err = NewEndpointTable(x.Endpoints)
+	if err != nil {
+		msgs.Add("endpoints: %s", err)
+	}
+
 	x.LocalAdminGroupsMap = StringSlice2Map(x.LocalAdminGroups,
 		func(name string) string {
 			return name
@@ -107,44 +198,50 @@ func (x *Auth) Check(cfg any) (err error) {
 		}
 	}
 
+	enabledCount := 0
+
 	for methodName, method := range x.Methods {
-		methodDef, exists := knownAuthMethods[methodName]
+		base := fmt.Sprintf(`auth method "%s"`, methodName)
+
+		if method == nil {
+			msgs.Add(`%s: empty definition`, base)
+			continue
+		}
+
+		methodDef, exists := getAuthMethod(methodName)
 		if !exists {
-			msgs.Add(`Unknown auth method "%s"`, methodName)
+			msgs.Add(`Unknown auth method "%s" (registered: %s)`, methodName, knownAuthMethodNames())
 			continue
 		}
 
-		base := fmt.Sprintf(`auth method "%s"`, methodName)
+		if !method.Enabled {
+			base += " (disabled)"
+		}
 
-		err = ConvExtra(&method.Options, methodDef.options)
+		err = ConvExtra(&method.Options, methodDef.newOptions(), DecodePath(fmt.Sprintf("methods.%s.options", methodName)))
 		if err != nil {
-			msgs.Add("%s: %s", base, err)
+			msgs.Add("%s", err)
 			continue
 		}
 
-		if method.Enabled {
-			e := methodDef.check.Call([]reflect.Value{reflect.ValueOf(cfg)})
-			if len(e) != 1 || e[0].Kind() != reflect.Interface {
-				msgs.Add(`%s: Check returned illegal value "#%v"`, base, x)
-				continue
-			}
-
-			if e[0].IsNil() {
-				continue
-			}
+		if method.Score == 0 {
+			method.Score = methodDef.info.DefaultScore
+		}
 
-			err, ok := e[0].Interface().(error)
-			if !ok {
-				msgs.Add(`%s: Check returned not an error value "#%v"`, base, x)
-				continue
-			}
+		// Options of disabled methods are checked too, so mistakes are not hidden until the method is enabled
+		err = callCheck(reflect.ValueOf(method.Options).MethodByName("Check"), cfg)
+		if err != nil {
+			msgs.Add(`%s: %s`, base, err)
+			continue
+		}
 
-			if err != nil {
-				msgs.Add(`%s: %s`, base, err)
-				continue
-			}
+		if method.Enabled {
+			enabledCount++
 		}
+	}
 
+	if enabledCount == 0 && x.requiresAuth() {
+		msgs.Add(`no enabled auth methods, but there are endpoints that require authentication`)
 	}
 
 	err = msgs.Error()
@@ -152,6 +249,31 @@ func (x *Auth) Check(cfg any) (err error) {
 	return
 }
 
+// GetAuthOptions -- typed options of the auth method, available after Check
+func GetAuthOptions[T any](auth *Auth, method string) (*T, bool) {
+	if auth == nil {
+		return nil, false
+	}
+
+	m, exists := auth.Methods[method]
+	if !exists || m == nil {
+		return nil, false
+	}
+
+	options, ok := m.Options.(*T)
+	return options, ok
+}
+
+// requiresAuth -- at least one endpoint has a non-empty users/groups list
+func (x *Auth) requiresAuth() bool {
+	for _, users := range x.Endpoints {
+		if len(users) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
 //----------------------------------------------------------------------------------------------------------------------------//
 
 // authSlice2Map --
@@ -159,7 +281,7 @@ func authSlice2Map(src map[string][]string) (dst map[string]misc.BoolMap) {
 	dst = make(map[string]misc.BoolMap, len(src))
 
 	for path, list := range src {
-		path = misc.NormalizeSlashes(path)
+		path = NormalizeEndpointPath(path)
 		mList := make(misc.BoolMap, len(list))
 		for _, u := range list {
 			u = strings.TrimSpace(u)
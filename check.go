@@ -1,10 +1,12 @@
 package config
 
 import (
+	"fmt"
 	"reflect"
 	"strings"
 	"time"
 
+	"github.com/alrusov/log"
 	"github.com/alrusov/misc"
 )
 
@@ -22,18 +24,90 @@ func (x *Common) Check(cfg any) (err error) {
 	if x.Timezone == "" {
 		x.Timezone = "UTC"
 	}
-	_, err = time.LoadLocation(x.Timezone)
+	x.Location, err = time.LoadLocation(x.Timezone)
 	if err != nil {
-		msgs.AddError(err)
+		msgs.Add("common.timezone: %s", err)
+	}
+
+	if x.MemoryLimit < 0 {
+		msgs.Add("common.memory-limit: negative value %d", x.MemoryLimit)
+	}
+
+	if x.MaxWorkersCount < 0 {
+		msgs.Add("common.max-workers-count: negative value %d", x.MaxWorkersCount)
 	}
 
 	if x.LoadAvgPeriod <= 0 {
 		x.LoadAvgPeriod = Duration(60 * time.Second)
 	}
 
+	x.checkLog(msgs)
+
 	return msgs.Error()
 }
 
+// checkLog -- validate the logging settings
+func (x *Common) checkLog(msgs *misc.Messages) {
+	var err error
+
+	checkLevel := func(name string, level *string) {
+		*level = strings.TrimSpace(*level)
+		if *level == "" {
+			return
+		}
+
+		_, ok := log.Str2Level(*level)
+		if !ok {
+			msgs.Add(`%s: unknown level "%s" (valid: %s)`, name, *level, strings.Join(log.GetLogLevels(), ", "))
+		}
+	}
+
+	checkLevel("common.log-level", &x.LogLevel)
+	checkLevel("common.mem-stats-level", &x.MemStatsLevel)
+
+	if len(x.LogLevels) != 0 {
+		levels := make(misc.StringMap, len(x.LogLevels))
+
+		for facility, level := range x.LogLevels {
+			name := strings.TrimSpace(facility)
+			if name == "" || strings.ContainsAny(name, " \t") {
+				msgs.Add(`common.log-levels: bad facility name "%s"`, facility)
+				continue
+			}
+
+			checkLevel(fmt.Sprintf(`common.log-levels."%s"`, name), &level)
+			levels[name] = level
+		}
+
+		x.LogLevels = levels
+	}
+
+	x.LogDir = strings.TrimSpace(x.LogDir)
+	if x.LogDir != "" && x.LogDir != "-" {
+		// a relative path is used relative to the executable file directory
+		x.LogDir, err = misc.AbsPath(x.LogDir)
+		if err != nil {
+			msgs.Add("common.log-dir: %s", err)
+		} else if x.CreateLogDir {
+			checkPath(msgs, "common.log-dir", x.LogDir, &pathOptions{dir: true, writable: true, create: true})
+		}
+	}
+
+	if x.LogBufferSize < 0 {
+		msgs.Add("common.log-buffer-size: negative value %d", x.LogBufferSize)
+	}
+
+	if x.LogBufferDelay < 0 {
+		msgs.Add("common.log-buffer-delay: negative value")
+	}
+
+	if x.LogMaxStringLen < 0 {
+		msgs.Add("common.log-max-string-len: negative value %d", x.LogMaxStringLen)
+	}
+
+	x.checkLogOutputs(msgs)
+}
+
 //----------------------------------------------------------------------------------------------------------------------------//
 
 // Check --
@@ -41,15 +115,7 @@ func (x *Listener) Check(cfg any) (err error) {
 	msgs := misc.NewMessages()
 	defer msgs.Free()
 
-	x.Addr = strings.TrimSpace(x.Addr)
-
-	if x.Addr == "" {
-		if x.SSLCombinedPem == "" {
-			x.Addr = ":80"
-		} else {
-			x.Addr = ":443"
-		}
-	}
+	x.checkAddr(msgs)
 
 	if x.Root != "" {
 		x.Root, err = misc.AbsPath(x.Root)
@@ -62,17 +128,32 @@ func (x *Listener) Check(cfg any) (err error) {
 		x.ProxyPrefix = misc.NormalizeSlashes("/" + x.ProxyPrefix)
 	}
 
-	if x.SSLCombinedPem != "" {
-		x.SSLCombinedPem, err = misc.AbsPath(x.SSLCombinedPem)
-		if err != nil {
-			msgs.Add("listener.ssl-combined-pem: %s", err)
-		}
+	err = x.Proxy.Check(cfg)
+	if err != nil {
+		msgs.Add("listener.proxy: %s", err)
+	}
+
+	err = x.CORS.Check(cfg)
+	if err != nil {
+		msgs.Add("listener.cors: %s", err)
+	}
+
+	err = x.Headers.Check(cfg)
+	if err != nil {
+		msgs.Add("listener.headers: %s", err)
+	}
+
+	err = x.checkTLS()
+	if err != nil {
+		msgs.AddError(err)
 	}
 
 	if x.Timeout <= 0 {
 		x.Timeout = ListenerDefaultTimeout
 	}
 
+	x.checkServer(msgs)
+
 	if x.IconFile != "" {
 		x.IconFile, err = misc.AbsPath(x.IconFile)
 		if err != nil {
@@ -86,6 +167,16 @@ func (x *Listener) Check(cfg any) (err error) {
 		},
 	)
 
+	x.disabledEndpoints, err = NewEndpointMatcher(x.DisabledEndpointsSlice)
+	if err != nil {
+		msgs.Add("listener.disabled-endpoints: %s", err)
+	}
+
+	err = x.Limits.Check(cfg)
+	if err != nil {
+		msgs.Add("listener.limits: %s", err)
+	}
+
 	err = x.Auth.Check(cfg)
 	if err != nil {
 		msgs.Add("listener.auth: %s", err)
@@ -94,12 +185,61 @@ func (x *Listener) Check(cfg any) (err error) {
 	return msgs.Error()
 }
 
+// bindAddr -- normalized Addr with the default port
+func (x *Listener) bindAddr() string {
+	addr := strings.TrimSpace(x.Addr)
+
+	if addr == "" {
+		if !x.IsTLS() {
+			addr = ":80"
+		} else {
+			addr = ":443"
+		}
+	}
+
+	return addr
+}
+
 // ----------------------------------------------------------------------------------------------------------------------------//
 // Check --
 func (x *DB) Check(cfg any) (err error) {
 	msgs := misc.NewMessages()
 	defer msgs.Free()
 
+	x.checkDSN(msgs)
+
+	if x.MaxConnection < 0 {
+		msgs.Add("db.max-conn: negative value %d", x.MaxConnection)
+	}
+
+	if x.MaxIdle < 0 {
+		msgs.Add("db.max-idle: negative value %d", x.MaxIdle)
+	} else if x.MaxConnection > 0 && x.MaxIdle > x.MaxConnection {
+		msgs.Add("db.max-idle: %d is greater than max-conn %d", x.MaxIdle, x.MaxConnection)
+	}
+
+	if x.ConnLifetime < 0 {
+		msgs.Add("db.conn-lifetime: negative value")
+	}
+
+	if x.IdleTimeout < 0 {
+		msgs.Add("db.idle-timeout: negative value")
+	}
+
+	if x.Retry < 0 {
+		msgs.Add("db.retry: negative value %d", x.Retry)
+	}
+
+	if x.RetryBackoff < 0 {
+		msgs.Add("db.retry-backoff: negative value")
+	} else if x.RetryBackoff == 0 && x.Retry > 0 {
+		x.RetryBackoff = DBDefaultRetryBackoff
+	}
+
+	if x.QueryTimeout < 0 {
+		msgs.Add("db.query-timeout: negative value")
+	}
+
 	return msgs.Error()
 }
 
@@ -110,6 +250,10 @@ func Check(cfg any, list []any) error {
 	msgs := misc.NewMessages()
 	defer msgs.Free()
 
+	// before the listed blocks, their Check methods may change the maps
+	checked, err := checkMapBlocks(cfg)
+	msgs.AddError(err)
+
 	for _, x := range list {
 		v := reflect.ValueOf(x)
 
@@ -118,9 +262,13 @@ func Check(cfg any, list []any) error {
 			continue
 		}
 
+		if checked[x] {
+			continue
+		}
+
 		m := v.MethodByName("Check")
 
-		if m.Kind() != reflect.Func {
+		if !isCheckMethod(m) {
 			msgs.Add(`"%#v" doesn't have the Check function`, x)
 			continue
 		}
@@ -149,9 +297,55 @@ func Check(cfg any, list []any) error {
 		msgs.Add("%s", err)
 	}
 
+	loadMapValues()
+
+	msgs.AddError(CheckPaths(cfg))
+	msgs.AddError(checkSections(cfg))
+	msgs.AddError(checkListeners())
+	msgs.AddError(checkDBs())
+
 	return msgs.Error()
 }
 
+var checkFuncTp = reflect.TypeOf((func(any) error)(nil))
+
+// isCheckMethod -- the method got by reflection is Check(cfg any) error. Check methods with other signatures
+// (Check() error of the application interface for example) are not called by the package
+func isCheckMethod(m reflect.Value) bool {
+	return m.IsValid() && m.Kind() == reflect.Func && m.Type() == checkFuncTp
+}
+
+// callCheck -- call the Check(cfg any) error method got by reflection
+func callCheck(m reflect.Value, cfg any) (err error) {
+	if !isCheckMethod(m) {
+		if !m.IsValid() {
+			return fmt.Errorf(`Check method not found`)
+		}
+		return fmt.Errorf(`Check method is "%s", "func(any) error" expected`, m.Type())
+	}
+
+	arg := reflect.ValueOf(cfg)
+	if !arg.IsValid() {
+		arg = reflect.Zero(m.Type().In(0))
+	}
+
+	e := m.Call([]reflect.Value{arg})
+	if len(e) != 1 || e[0].Kind() != reflect.Interface {
+		return fmt.Errorf(`Check returned an illegal value`)
+	}
+
+	if e[0].IsNil() {
+		return
+	}
+
+	err, ok := e[0].Interface().(error)
+	if !ok {
+		return fmt.Errorf(`Check returned not an error value "%#v"`, e[0].Interface())
+	}
+
+	return
+}
+
 //----------------------------------------------------------------------------------------------------------------------------//
 
 // StringSlice2Map --
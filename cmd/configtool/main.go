@@ -0,0 +1,323 @@
+// configtool -- checks and compares config files using the same preprocessor as config.LoadFile
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alrusov/config"
+	"github.com/alrusov/log"
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+type command struct {
+	args     string
+	help     string
+	minArgs  int
+	maxArgs  int
+	numbered bool // the -n flag is allowed
+	do       func(args []string, opts *options) (exitCode int, err error)
+}
+
+type options struct {
+	numbered bool
+	execDir  string
+}
+
+var (
+	commands = map[string]*command{
+		"render": {
+			args:     "[-n] <file>",
+			help:     "text after the preprocessing as GetText returns it, -n numbers the lines",
+			minArgs:  1,
+			maxArgs:  1,
+			numbered: true,
+			do:       doRender,
+		},
+		"secure": {
+			args:     "[-n] <file>",
+			help:     "text after the preprocessing with the secrets masked as GetSecuredText returns it",
+			minArgs:  1,
+			maxArgs:  1,
+			numbered: true,
+			do:       doSecure,
+		},
+		"validate": {
+			args:    "<file>",
+			help:    "preprocess and parse the file, errors are reported as file:line of the source file",
+			minArgs: 1,
+			maxArgs: 1,
+			do:      doValidate,
+		},
+		"includes": {
+			args:    "<file>",
+			help:    "include tree of the file",
+			minArgs: 1,
+			maxArgs: 1,
+			do:      doIncludes,
+		},
+		"env": {
+			args:    "<file>",
+			help:    "referenced environment variables, the exit code is 1 if some of them are not set",
+			minArgs: 1,
+			maxArgs: 1,
+			do:      doEnv,
+		},
+		"diff": {
+			args:    "<old-file> <new-file>",
+			help:    "changes between two files after the preprocessing, secrets are masked",
+			minArgs: 2,
+			maxArgs: 2,
+			do:      doDiff,
+		},
+	}
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+func main() {
+	// the preprocessor messages must not be mixed with the output
+	log.SetConsoleWriter(os.Stderr)
+
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage()
+		return 2
+	}
+
+	name := args[0]
+
+	cmd, exists := commands[name]
+	if !exists {
+		fmt.Fprintf(os.Stderr, "Unknown command \"%s\"\n\n", name)
+		usage()
+		return 2
+	}
+
+	opts := &options{}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if cmd.numbered {
+		fs.BoolVar(&opts.numbered, "n", false, "number the lines")
+	}
+	fs.StringVar(&opts.execDir, "exec-dir", "", "directory of the application executable")
+
+	err := fs.Parse(args[1:])
+	args = fs.Args()
+
+	if err != nil || len(args) < cmd.minArgs || len(args) > cmd.maxArgs {
+		fmt.Fprintf(os.Stderr, "Usage: configtool %s [-exec-dir <dir>] %s\n", name, cmd.args)
+		return 2
+	}
+
+	code, err := cmd.do(args, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		if code == 0 {
+			code = 1
+		}
+	}
+
+	return code
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b := new(strings.Builder)
+	b.WriteString("Usage: configtool <command> [-exec-dir <dir>] [arguments]\n\n")
+	b.WriteString("Relative include names without the @, $ and ^ prefixes are resolved against the directory of the application\n")
+	b.WriteString("executable, -exec-dir sets it. The directory of the config file is used by default.\n\nCommands:\n")
+	for _, name := range names {
+		cmd := commands[name]
+		fmt.Fprintf(b, "  %s %s\n      %s\n", name, cmd.args, cmd.help)
+	}
+
+	fmt.Fprint(os.Stderr, b.String())
+}
+
+// absPath -- the file names in the arguments are relative to the current directory, not to the application directory
+func absPath(name string) string {
+	s, err := filepath.Abs(name)
+	if err != nil {
+		return name
+	}
+	return s
+}
+
+// setExecDir -- the application directory for the relative include names
+func setExecDir(fileName string, opts *options) {
+	dir := opts.execDir
+	if dir == "" {
+		dir = filepath.Dir(fileName)
+	}
+	config.SetExecDir(absPath(dir))
+}
+
+// preprocess -- the file after the preprocessing, info is nil if the file was not read
+func preprocess(fileName string, opts *options) (*config.PreprocessInfo, error) {
+	setExecDir(absPath(fileName), opts)
+
+	info, err := config.PreprocessFile(absPath(fileName))
+	if err != nil {
+		return info, fmt.Errorf("%s: %s", fileName, err)
+	}
+
+	return info, nil
+}
+
+func writeText(text []byte, numbered bool) {
+	text = bytes.TrimRight(text, "\n")
+
+	if !numbered {
+		fmt.Fprintf(os.Stdout, "%s\n", text)
+		return
+	}
+
+	for i, line := range bytes.Split(text, []byte("\n")) {
+		fmt.Fprintf(os.Stdout, "%04d | %s\n", i+1, line)
+	}
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+func doRender(args []string, opts *options) (int, error) {
+	info, err := preprocess(args[0], opts)
+	if err != nil {
+		return 1, err
+	}
+
+	writeText(info.Text, opts.numbered)
+	return 0, nil
+}
+
+func doSecure(args []string, opts *options) (int, error) {
+	info, err := preprocess(args[0], opts)
+	if err != nil {
+		return 1, err
+	}
+
+	writeText([]byte(config.SecureText(string(info.Text))), opts.numbered)
+	return 0, nil
+}
+
+func doValidate(args []string, opts *options) (int, error) {
+	info, err := preprocess(args[0], opts)
+	if err != nil {
+		return 1, err
+	}
+
+	err = info.Validate()
+	if err != nil {
+		return 1, err
+	}
+
+	for _, name := range sortedKeys(info.EnvRefs) {
+		if !info.EnvRefs[name] {
+			fmt.Fprintf(os.Stderr, "Warning: environment variable \"%s\" is not set, the empty value is used\n", name)
+		}
+	}
+
+	fmt.Fprintf(os.Stdout, "%s: OK\n", args[0])
+	return 0, nil
+}
+
+func doIncludes(args []string, opts *options) (int, error) {
+	info, err := preprocess(args[0], opts)
+	if info == nil {
+		return 1, err
+	}
+
+	var show func(node *config.Include, indent string)
+	show = func(node *config.Include, indent string) {
+		notes := []string{}
+		if !node.Mandatory {
+			notes = append(notes, "optional")
+		}
+		if node.Missing {
+			notes = append(notes, "missing")
+		}
+
+		s := ""
+		if len(notes) != 0 {
+			s = " (" + strings.Join(notes, ", ") + ")"
+		}
+
+		fmt.Fprintf(os.Stdout, "%s%s%s\n", indent, node.File, s)
+
+		for _, child := range node.Includes {
+			show(child, indent+"  ")
+		}
+	}
+
+	show(info.Includes, "")
+
+	// the tree is shown with the errors too, the missing mandatory file is marked in it
+	return 0, err
+}
+
+func doEnv(args []string, opts *options) (int, error) {
+	info, err := preprocess(args[0], opts)
+	if info == nil {
+		return 1, err
+	}
+
+	code := 0
+
+	for _, name := range sortedKeys(info.EnvRefs) {
+		state := "set"
+		if !info.EnvRefs[name] {
+			state = "unset"
+			code = 1
+		}
+		fmt.Fprintf(os.Stdout, "%s\t%s\n", name, state)
+	}
+
+	return code, err
+}
+
+// doDiff -- exit code is 1 if the files differ
+func doDiff(args []string, opts *options) (int, error) {
+	setExecDir(absPath(args[0]), opts)
+
+	list, err := config.DiffFiles(absPath(args[0]), absPath(args[1]))
+	if err != nil {
+		return 2, err
+	}
+
+	for _, c := range list {
+		fmt.Fprintln(os.Stdout, c.String())
+	}
+
+	if len(list) != 0 {
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	list := make([]string, 0, len(m))
+	for k := range m {
+		list = append(list, k)
+	}
+	sort.Strings(list)
+	return list
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"crypto/tls"
 	"time"
 
 	"github.com/alrusov/misc"
@@ -20,18 +21,22 @@ type (
 		Description string `toml:"description"`
 		Class       string `toml:"class"`
 
-		Timezone string `toml:"timezone"`
+		Timezone string         `toml:"timezone"`
+		Location *time.Location `toml:"-" json:"-"` // loaded Timezone, set by Check
 
 		LogLocalTime    bool           `toml:"log-local-time"`
 		LogDir          string         `toml:"log-dir"`
-		LogLevel        string         `toml:"log-level"`  // default
-		LogLevels       misc.StringMap `toml:"log-levels"` // by facilities
+		CreateLogDir    bool           `toml:"create-log-dir"`           // create LogDir in Check if it does not exist
+		LogLevel        string         `toml:"log-level" reload:"live"`  // default
+		LogLevels       misc.StringMap `toml:"log-levels" reload:"live"` // by facilities
 		LogBufferSize   int            `toml:"log-buffer-size"`
 		LogBufferDelay  Duration       `toml:"log-buffer-delay"`
 		LogMaxStringLen int            `toml:"log-max-string-len"`
+		LogOutputs      []LogOutput    `toml:"log-output"`
 
-		GoMaxProcs int `toml:"go-max-procs"`
-		GCPercent  int `toml:"gc-percent"`
+		GoMaxProcs  int   `toml:"go-max-procs" reload:"restart"` // 0 - runtime default, <0 - runtime default minus the value (at least 1)
+		GCPercent   int   `toml:"gc-percent"`                    // 0 - do not change, <0 - disable GC
+		MemoryLimit int64 `toml:"memory-limit"`                  // in bytes, 0 - do not change
 
 		MemStatsPeriod Duration `toml:"mem-stats-period"`
 		MemStatsLevel  string   `toml:"mem-stats-level"`
@@ -52,34 +57,59 @@ type (
 
 	// Listener --
 	Listener struct {
-		// Addr should be set to the desired listening host:port
-		Addr      string `toml:"bind-addr"`
-		DebugAddr string `toml:"debug-bind-addr"`
+		// Addr should be set to the desired listening host:port or unix:<socket path>
+		Addr      string `toml:"bind-addr" reload:"restart"`
+		DebugAddr string `toml:"debug-bind-addr" reload:"restart"`
 
-		Root string `toml:"root"` // in filesystem
+		// Try to listen on Addr and DebugAddr in Check to find occupied ports before the service starts
+		ProbeBind bool `toml:"probe-bind"`
 
-		ProxyPrefix string `toml:"proxy-prefix"`
+		Root string `toml:"root" path:"config-relative"` // in filesystem
+
+		ProxyPrefix string        `toml:"proxy-prefix"`
+		Proxy       ListenerProxy `toml:"proxy"`
+
+		CORS    ListenerCORS    `toml:"cors"`
+		Headers ListenerHeaders `toml:"headers"`
 
 		// Set certificate in order to handle HTTPS requests
-		SSLCombinedPem string `toml:"ssl-combined-pem"`
+		SSLCombinedPem string `toml:"ssl-combined-pem" path:"config-relative"`
+
+		// Extended TLS settings
+		TLS       *ListenerTLS `toml:"tls"`
+		tlsConfig *tls.Config  // prepared by Check
 
 		//
 		Timeout Duration `toml:"timeout"`
 
-		IconFile string `toml:"icon-file"`
+		// HTTP server settings, Timeout is used as the default for the read and write timeouts
+		ReadHeaderTimeout Duration `toml:"read-header-timeout"`
+		ReadTimeout       Duration `toml:"read-timeout"`
+		WriteTimeout      Duration `toml:"write-timeout"`
+		IdleTimeout       Duration `toml:"idle-timeout"`
+		ShutdownTimeout   Duration `toml:"shutdown-timeout"`
+		MaxHeaderBytes    int      `toml:"max-header-bytes"`
+		MaxBodySize       int64    `toml:"max-body-size"` // 0 - unlimited
+		DisableKeepAlive  bool     `toml:"disable-keep-alive"`
+
+		IconFile string `toml:"icon-file" path:"config-relative"`
+
+		Limits ListenerLimits `toml:"limits"`
 
-		DisabledEndpointsSlice []string     `toml:"disabled-endpoints"`
-		DisabledEndpoints      misc.BoolMap `toml:"-"`
+		DisabledEndpointsSlice []string         `toml:"disabled-endpoints" reload:"live"`
+		DisabledEndpoints      misc.BoolMap     `toml:"-"`
+		disabledEndpoints      *EndpointMatcher // compiled DisabledEndpointsSlice
 
 		Auth Auth `toml:"auth"`
 	}
 
 	// Auth --
 	Auth struct {
-		EndpointsSlice map[string][]string     `toml:"endpoints"`
-		Endpoints      map[string]misc.BoolMap `toml:"-"`
+		EndpointsSlice map[string][]string          `toml:"endpoints" reload:"live"`
+		Endpoints      map[string]misc.BoolMap      `toml:"-"`
+		endpoints      *EndpointTable[misc.BoolMap] // compiled Endpoints
 
-		UsersMap misc.StringMap  `toml:"users"`
+		UsersMap misc.StringMap  `toml:"users" reload:"live"`
 		Users    map[string]User `toml:"-"`
 
 		Realm string `toml:"realm"`
@@ -104,10 +134,27 @@ type (
 
 	// DB --
 	DB struct {
-		Type          string `toml:"type"`
-		DSN           string `toml:"dsn"`
-		MaxConnection int    `toml:"max-conn"`
-		Retry         int    `toml:"retry"`
+		Type string `toml:"type" reload:"restart"` // postgres, mysql, clickhouse, sqlite or registered by AddDBDriver
+
+		// Raw driver DSN or the structured fields below
+		DSN string `toml:"dsn"`
+
+		Host     string         `toml:"host"`
+		Port     int            `toml:"port"`
+		User     string         `toml:"user"`
+		Password string         `toml:"password"`
+		Database string         `toml:"database"` // file name for sqlite
+		Params   misc.StringMap `toml:"params"`
+
+		MaxConnection int      `toml:"max-conn"`
+		MaxIdle       int      `toml:"max-idle"`
+		ConnLifetime  Duration `toml:"conn-lifetime"`
+		IdleTimeout   Duration `toml:"idle-timeout"`
+
+		Retry        int      `toml:"retry"`
+		RetryBackoff Duration `toml:"retry-backoff"`
+
+		QueryTimeout Duration `toml:"query-timeout"`
 	}
 )
 
@@ -122,13 +169,30 @@ const (
 	AuthMethodKrb5 = "krb5"
 )
 
+const (
+	// DefaultDBName -- name of the default connection in [db.<name>] tables
+	DefaultDBName = "default"
+)
+
 const (
 	// ListenerDefaultTimeout --
 	ListenerDefaultTimeout = Duration(5 * time.Second)
 
+	// ListenerDefaultIdleTimeout --
+	ListenerDefaultIdleTimeout = Duration(120 * time.Second)
+
+	// ListenerDefaultShutdownTimeout --
+	ListenerDefaultShutdownTimeout = Duration(30 * time.Second)
+
+	// ListenerDefaultMaxHeaderBytes --
+	ListenerDefaultMaxHeaderBytes = 1 << 20
+
 	// ClientDefaultTimeout --
 	ClientDefaultTimeout = Duration(5 * time.Second)
 
+	// DBDefaultRetryBackoff --
+	DBDefaultRetryBackoff = Duration(time.Second)
+
 	// JWTdefaultLifetimeAccess --
 	JWTdefaultLifetimeAccess = Duration(time.Hour)
 
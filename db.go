@@ -0,0 +1,436 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/alrusov/misc"
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+type (
+	// DBDriver -- known database type
+	DBDriver struct {
+		DefaultPort int
+
+		// ParseDSN -- validate the driver specific DSN
+		ParseDSN func(dsn string) error
+
+		// BuildDSN -- DSN from the structured fields
+		BuildDSN func(x *DB) string
+	}
+)
+
+const (
+	// DBTypePostgres --
+	DBTypePostgres = "postgres"
+	// DBTypeMySQL --
+	DBTypeMySQL = "mysql"
+	// DBTypeClickHouse --
+	DBTypeClickHouse = "clickhouse"
+	// DBTypeSQLite --
+	DBTypeSQLite = "sqlite"
+)
+
+var (
+	knownDBDriversMutex = new(sync.RWMutex)
+	knownDBDrivers      = map[string]*DBDriver{
+		DBTypePostgres: {
+			DefaultPort: 5432,
+			ParseDSN:    parsePostgresDSN,
+			BuildDSN: func(x *DB) string {
+				return buildURLDSN("postgres", x)
+			},
+		},
+		DBTypeMySQL: {
+			DefaultPort: 3306,
+			ParseDSN:    parseMySQLDSN,
+			BuildDSN:    buildMySQLDSN,
+		},
+		DBTypeClickHouse: {
+			DefaultPort: 9000,
+			ParseDSN:    parseClickHouseDSN,
+			BuildDSN: func(x *DB) string {
+				return buildURLDSN("clickhouse", x)
+			},
+		},
+		DBTypeSQLite: {
+			ParseDSN: parseSQLiteDSN,
+			BuildDSN: buildSQLiteDSN,
+		},
+	}
+
+	// user:password@protocol(address)/dbname?param=value
+	reMySQLDSN = regexp.MustCompile(`^(?:([^:@]*)(?::(.*))?@)?(?:([a-z0-9]+)(?:\(([^)]*)\))?)?/([^?]*)(?:\?(.*))?$`)
+
+	// key=value pairs
+	reKVPassword = regexp.MustCompile(`(?i)(\bpassword\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+)
+
+const (
+	maskedPassword = "xxxxx" // as in url.URL.Redacted
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// AddDBDriver --
+func AddDBDriver(name string, driver *DBDriver) (err error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return fmt.Errorf(`empty driver name`)
+	}
+
+	if driver == nil || driver.ParseDSN == nil || driver.BuildDSN == nil {
+		return fmt.Errorf(`driver "%s": ParseDSN and BuildDSN must be defined`, name)
+	}
+
+	knownDBDriversMutex.Lock()
+	defer knownDBDriversMutex.Unlock()
+
+	_, exists := knownDBDrivers[name]
+	if exists {
+		return fmt.Errorf(`driver "%s" is already defined`, name)
+	}
+
+	knownDBDrivers[name] = driver
+	return
+}
+
+func getDBDriver(name string) (driver *DBDriver, exists bool) {
+	knownDBDriversMutex.RLock()
+	defer knownDBDriversMutex.RUnlock()
+
+	driver, exists = knownDBDrivers[name]
+	return
+}
+
+func knownDBDriverNames() string {
+	knownDBDriversMutex.RLock()
+	defer knownDBDriversMutex.RUnlock()
+
+	names := make([]string, 0, len(knownDBDrivers))
+	for name := range knownDBDrivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// checkDSN -- validate the type and the DSN or the structured fields
+func (x *DB) checkDSN(msgs *misc.Messages) {
+	x.Type = strings.ToLower(strings.TrimSpace(x.Type))
+	if x.Type == "" {
+		msgs.Add("db.type: not defined")
+		return
+	}
+
+	driver, exists := getDBDriver(x.Type)
+	if !exists {
+		msgs.Add(`db.type: unknown type "%s" (known: %s)`, x.Type, knownDBDriverNames())
+		return
+	}
+
+	x.DSN = strings.TrimSpace(x.DSN)
+	x.Host = strings.TrimSpace(x.Host)
+
+	structured := x.Host != "" || x.Port != 0 || x.User != "" || x.Password != "" || x.Database != "" || len(x.Params) != 0
+
+	switch {
+	case x.DSN != "" && structured:
+		msgs.Add("db: dsn and host/port/user/password/database/params are mutually exclusive")
+		return
+
+	case x.DSN != "":
+		err := driver.ParseDSN(x.DSN)
+		if err != nil {
+			msgs.Add("db.dsn: %s", err)
+		}
+		return
+
+	case !structured:
+		msgs.Add("db: neither dsn nor host/database are defined")
+		return
+	}
+
+	if x.Type == DBTypeSQLite {
+		if x.Database == "" {
+			msgs.Add("db.database: sqlite file name is not defined")
+		}
+		return
+	}
+
+	if x.Host == "" {
+		x.Host = "localhost"
+	}
+
+	if x.Port == 0 {
+		x.Port = driver.DefaultPort
+	}
+
+	if x.Port < 0 || x.Port > 65535 {
+		msgs.Add("db.port: %d is out of range", x.Port)
+	}
+
+	if x.Type == DBTypeMySQL {
+		// the driver takes the user up to the first ":" and the database after the last "/", the password may contain any characters
+		if strings.Contains(x.User, ":") {
+			msgs.Add(`db.user: ":" is not allowed for mysql`)
+		}
+		if strings.ContainsAny(x.Database, "/?") {
+			msgs.Add(`db.database: "/" and "?" are not allowed for mysql`)
+		}
+	}
+
+	err := driver.ParseDSN(x.BuildDSN())
+	if err != nil {
+		msgs.Add("db: %s", err)
+	}
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// BuildDSN -- the driver connection string, DSN as is or built from the structured fields
+func (x *DB) BuildDSN() string {
+	if x.DSN != "" {
+		return x.DSN
+	}
+
+	driver, exists := getDBDriver(strings.ToLower(strings.TrimSpace(x.Type)))
+	if !exists {
+		return ""
+	}
+
+	return driver.BuildDSN(x)
+}
+
+// SecuredDSN -- BuildDSN with the masked password for logs
+func (x *DB) SecuredDSN() string {
+	return MaskDSN(x.BuildDSN())
+}
+
+// MaskDSN -- replace the password in URL, MySQL and key=value DSN forms
+func MaskDSN(dsn string) string {
+	if scheme, rest, isURL := strings.Cut(dsn, "://"); isURL {
+		return maskURLPassword(scheme, rest)
+	}
+
+	m := reMySQLDSN.FindStringSubmatchIndex(dsn)
+	if m != nil && m[4] >= 0 && m[5] > m[4] {
+		return dsn[:m[4]] + maskedPassword + dsn[m[5]:]
+	}
+
+	return reKVPassword.ReplaceAllString(dsn, "${1}"+maskedPassword)
+}
+
+// maskURLPassword -- everything between "user:" and the last "@" is the password, it may contain unescaped "/", ":" and "@"
+func maskURLPassword(scheme string, rest string) string {
+	dsn := scheme + "://" + rest
+
+	if strings.Count(rest, "@") == 1 {
+		u, err := url.Parse(dsn)
+		if err == nil && u.User != nil {
+			if _, exists := u.User.Password(); !exists {
+				return dsn
+			}
+			return u.Redacted()
+		}
+	}
+
+	at := strings.LastIndexByte(rest, '@')
+	if at < 0 {
+		return dsn
+	}
+
+	user, _, withPassword := strings.Cut(rest[:at], ":")
+	if !withPassword {
+		return dsn
+	}
+
+	return scheme + "://" + user + ":" + maskedPassword + rest[at:]
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+func dbQuery(params misc.StringMap) string {
+	if len(params) == 0 {
+		return ""
+	}
+
+	q := url.Values{}
+	for k, v := range params {
+		q.Set(k, v)
+	}
+
+	return "?" + q.Encode()
+}
+
+func buildURLDSN(scheme string, x *DB) string {
+	u := url.URL{
+		Scheme: scheme,
+		Host:   net.JoinHostPort(x.Host, strconv.Itoa(x.Port)),
+		Path:   "/" + x.Database,
+	}
+
+	switch {
+	case x.Password != "":
+		u.User = url.UserPassword(x.User, x.Password)
+	case x.User != "":
+		u.User = url.User(x.User)
+	}
+
+	return u.String() + dbQuery(x.Params)
+}
+
+// buildMySQLDSN -- as mysql.Config.FormatDSN does it, the parser of the driver splits the user and the password
+// by the last "@" and the first ":", so the password is not escaped
+func buildMySQLDSN(x *DB) string {
+	auth := ""
+	switch {
+	case x.Password != "":
+		auth = x.User + ":" + x.Password + "@"
+	case x.User != "":
+		auth = x.User + "@"
+	}
+
+	return auth + "tcp(" + net.JoinHostPort(x.Host, strconv.Itoa(x.Port)) + ")/" + x.Database + dbQuery(x.Params)
+}
+
+func buildSQLiteDSN(x *DB) string {
+	if len(x.Params) == 0 {
+		return x.Database
+	}
+	return "file:" + x.Database + dbQuery(x.Params)
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+func parsePostgresDSN(dsn string) error {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return err
+		}
+		if u.Host == "" && u.Query().Get("host") == "" {
+			return fmt.Errorf("host is not defined")
+		}
+		return nil
+	}
+
+	// key=value form
+	params, err := parseKV(dsn)
+	if err != nil {
+		return err
+	}
+
+	if len(params) == 0 {
+		return fmt.Errorf(`neither "postgres://" URL nor key=value pairs`)
+	}
+
+	return nil
+}
+
+func parseKV(dsn string) (params misc.StringMap, err error) {
+	params = misc.StringMap{}
+
+	s := strings.TrimSpace(dsn)
+	for s != "" {
+		k, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			return nil, fmt.Errorf(`bad key=value pair "%s"`, s)
+		}
+
+		k = strings.TrimSpace(k)
+		if k == "" || strings.ContainsAny(k, " \t") {
+			return nil, fmt.Errorf(`bad key in "%s"`, s)
+		}
+
+		rest = strings.TrimLeft(rest, " \t")
+
+		v := ""
+		if strings.HasPrefix(rest, "'") {
+			end := -1
+			for i := 1; i < len(rest); i++ {
+				if rest[i] == '\\' {
+					i++
+					continue
+				}
+				if rest[i] == '\'' {
+					end = i
+					break
+				}
+			}
+			if end < 0 {
+				return nil, fmt.Errorf(`unterminated quoted value for "%s"`, k)
+			}
+			v = rest[1:end]
+			rest = rest[end+1:]
+		} else {
+			v, rest, _ = strings.Cut(rest, " ")
+		}
+
+		params[k] = v
+		s = strings.TrimSpace(rest)
+	}
+
+	return
+}
+
+func parseMySQLDSN(dsn string) error {
+	m := reMySQLDSN.FindStringSubmatch(dsn)
+	if m == nil {
+		return fmt.Errorf(`expected "[user[:password]@][protocol[(address)]]/dbname[?params]"`)
+	}
+
+	if m[6] != "" {
+		_, err := url.ParseQuery(m[6])
+		if err != nil {
+			return fmt.Errorf("params: %s", err)
+		}
+	}
+
+	return nil
+}
+
+func parseClickHouseDSN(dsn string) error {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return err
+	}
+
+	switch u.Scheme {
+	case "clickhouse", "tcp", "http", "https":
+	default:
+		return fmt.Errorf(`unknown scheme "%s", expected clickhouse, tcp, http or https`, u.Scheme)
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("host is not defined")
+	}
+
+	return nil
+}
+
+func parseSQLiteDSN(dsn string) error {
+	if dsn == "file:" {
+		return fmt.Errorf("file name is not defined")
+	}
+
+	if strings.HasPrefix(dsn, "file:") {
+		_, err := url.Parse(dsn)
+		return err
+	}
+
+	return nil
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
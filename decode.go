@@ -0,0 +1,579 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alrusov/misc"
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+type (
+	// DecodeOption -- option of DecodeExtra
+	DecodeOption func(o *decodeOptions)
+
+	decodeOptions struct {
+		strict bool
+		path   string
+	}
+)
+
+var (
+	textUnmarshalerTp = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeTp            = reflect.TypeOf(time.Time{})
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// DecodeStrict -- keys without the corresponding fields are errors
+func DecodeStrict() DecodeOption {
+	return func(o *decodeOptions) {
+		o.strict = true
+	}
+}
+
+// DecodePath -- path of the source in the config, used as a prefix in the error messages
+func DecodePath(path string) DecodeOption {
+	return func(o *decodeOptions) {
+		o.path = path
+	}
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// DecodeExtra -- decode the free-form data (map[string]any, misc.InterfaceMap, nested maps and slices) into a new T.
+// Struct fields are matched by the toml tag names, untagged fields by the names ignoring case, "_" and "-".
+// Field tags:
+//
+//	default:"10s"                   value if the key is absent, comma separated for slices
+//	validate:"required,min=1,max=10,oneof=a b c"
+//
+// min and max are compared with the number value or with the length of strings, slices and maps.
+// All problems are returned in one error, each message begins with the key path
+func DecodeExtra[T any](src any, opts ...DecodeOption) (*T, error) {
+	switch v := src.(type) {
+	case *T:
+		if v != nil {
+			return v, nil
+		}
+		src = nil
+	case T:
+		return &v, nil
+	}
+
+	obj := new(T)
+
+	err := decodeExtra(reflect.ValueOf(obj).Elem(), src, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+func decodeExtra(dst reflect.Value, src any, opts ...DecodeOption) error {
+	o := &decodeOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	msgs := misc.NewMessages()
+	defer msgs.Free()
+
+	decodeValue(msgs, dst, src, o.path, o)
+
+	return msgs.Error()
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+func decodeValue(msgs *misc.Messages, dst reflect.Value, src any, path string, o *decodeOptions) {
+	if src == nil {
+		if dst.Kind() == reflect.Struct {
+			// defaults and required fields
+			decodeStruct(msgs, dst, map[string]reflect.Value{}, path, o)
+		}
+		return
+	}
+
+	sv := reflect.ValueOf(src)
+
+	if sv.Type().AssignableTo(dst.Type()) && sv.Kind() != reflect.Map && sv.Kind() != reflect.Slice {
+		dst.Set(sv)
+		return
+	}
+
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		decodeValue(msgs, dst.Elem(), src, path, o)
+		return
+	}
+
+	if dst.CanAddr() && dst.Addr().Type().Implements(textUnmarshalerTp) {
+		s, ok := scalarText(sv)
+		if !ok {
+			msgs.Add("%s: expected string, got %s", pathName(path), kindName(sv))
+			return
+		}
+
+		err := dst.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+		if err != nil {
+			msgs.Add("%s: %s", pathName(path), err)
+		}
+		return
+	}
+
+	switch dst.Kind() {
+	case reflect.Interface:
+		if !sv.Type().AssignableTo(dst.Type()) {
+			msgs.Add("%s: %s is not assignable to %s", pathName(path), kindName(sv), dst.Type())
+			return
+		}
+		dst.Set(sv)
+
+	case reflect.Bool:
+		if sv.Kind() != reflect.Bool {
+			msgs.Add("%s: expected boolean, got %s", pathName(path), kindName(sv))
+			return
+		}
+		dst.SetBool(sv.Bool())
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := toInt64(sv)
+		if !ok {
+			msgs.Add("%s: expected integer, got %s", pathName(path), kindName(sv))
+			return
+		}
+		if dst.OverflowInt(n) {
+			msgs.Add("%s: %d is out of range of %s", pathName(path), n, dst.Type())
+			return
+		}
+		dst.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := toInt64(sv)
+		if !ok {
+			msgs.Add("%s: expected integer, got %s", pathName(path), kindName(sv))
+			return
+		}
+		if n < 0 || dst.OverflowUint(uint64(n)) {
+			msgs.Add("%s: %d is out of range of %s", pathName(path), n, dst.Type())
+			return
+		}
+		dst.SetUint(uint64(n))
+
+	case reflect.Float32, reflect.Float64:
+		f, ok := toFloat64(sv)
+		if !ok {
+			msgs.Add("%s: expected number, got %s", pathName(path), kindName(sv))
+			return
+		}
+		if dst.OverflowFloat(f) {
+			msgs.Add("%s: %g is out of range of %s", pathName(path), f, dst.Type())
+			return
+		}
+		dst.SetFloat(f)
+
+	case reflect.String:
+		if sv.Kind() != reflect.String {
+			msgs.Add("%s: expected string, got %s", pathName(path), kindName(sv))
+			return
+		}
+		dst.SetString(sv.String())
+
+	case reflect.Slice, reflect.Array:
+		if sv.Kind() != reflect.Slice && sv.Kind() != reflect.Array {
+			msgs.Add("%s: expected array, got %s", pathName(path), kindName(sv))
+			return
+		}
+
+		n := sv.Len()
+		if dst.Kind() == reflect.Slice {
+			dst.Set(reflect.MakeSlice(dst.Type(), n, n))
+		} else if n > dst.Len() {
+			msgs.Add("%s: too many elements %d, %d at most is allowed", pathName(path), n, dst.Len())
+			return
+		}
+
+		for i := range n {
+			decodeValue(msgs, dst.Index(i), sv.Index(i).Interface(), fmt.Sprintf("%s[%d]", path, i), o)
+		}
+
+	case reflect.Map:
+		if dst.Type().Key().Kind() != reflect.String {
+			msgs.Add("%s: unsupported map key type %s", pathName(path), dst.Type().Key())
+			return
+		}
+
+		m, ok := srcMap(sv)
+		if !ok {
+			msgs.Add("%s: expected table, got %s", pathName(path), kindName(sv))
+			return
+		}
+
+		dst.Set(reflect.MakeMapWithSize(dst.Type(), len(m)))
+		for k, v := range m {
+			e := reflect.New(dst.Type().Elem()).Elem()
+			decodeValue(msgs, e, v.Interface(), joinPath(path, k), o)
+			dst.SetMapIndex(reflect.ValueOf(k).Convert(dst.Type().Key()), e)
+		}
+
+	case reflect.Struct:
+		m, ok := srcMap(sv)
+		if !ok {
+			msgs.Add("%s: expected table, got %s", pathName(path), kindName(sv))
+			return
+		}
+
+		decodeStruct(msgs, dst, m, path, o)
+
+	default:
+		msgs.Add("%s: unsupported type %s", pathName(path), dst.Type())
+	}
+}
+
+func decodeStruct(msgs *misc.Messages, dst reflect.Value, m map[string]reflect.Value, path string, o *decodeOptions) {
+	used := make(misc.BoolMap, len(m))
+
+	decodeStructFields(msgs, dst, m, used, path, o)
+
+	if !o.strict {
+		return
+	}
+
+	unknown := make([]string, 0, len(m))
+	for k := range m {
+		if !used[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+
+	for _, k := range unknown {
+		msgs.Add("%s: unknown key", joinPath(path, k))
+	}
+}
+
+func decodeStructFields(msgs *misc.Messages, dst reflect.Value, m map[string]reflect.Value, used misc.BoolMap, path string, o *decodeOptions) {
+	tp := dst.Type()
+
+	for i := range dst.NumField() {
+		t := tp.Field(i)
+		f := dst.Field(i)
+
+		_, tagged := t.Tag.Lookup("toml")
+
+		if t.Anonymous && !tagged && t.Type.Kind() == reflect.Struct {
+			// embedded struct fields are in the same table, the exported ones are settable even if the type is not exported
+			decodeStructFields(msgs, f, m, used, path, o)
+			continue
+		}
+
+		if !t.IsExported() || !f.CanSet() {
+			continue
+		}
+
+		name := misc.StructTagName(&t, "toml")
+		if name == "-" {
+			continue
+		}
+
+		key := name
+		v, exists := m[key]
+		if !exists && !tagged {
+			norm := normKeyName(name)
+			for k, kv := range m {
+				if normKeyName(k) == norm {
+					key, v, exists = k, kv, true
+					break
+				}
+			}
+		}
+
+		fPath := joinPath(path, key)
+
+		if exists {
+			used[key] = true
+			decodeValue(msgs, f, v.Interface(), fPath, o)
+		} else if def, ok := t.Tag.Lookup("default"); ok {
+			err := setTagValue(f, def)
+			if err != nil {
+				msgs.Add(`%s: bad default value "%s": %s`, pathName(fPath), def, err)
+				continue
+			}
+			exists = true
+		}
+
+		validate := t.Tag.Get("validate")
+		if validate != "" {
+			validateValue(msgs, f, exists, validate, fPath)
+		}
+	}
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// validateValue -- check the value according to the validate tag
+func validateValue(msgs *misc.Messages, v reflect.Value, exists bool, rules string, path string) {
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		name, arg, _ := strings.Cut(rule, "=")
+
+		switch name {
+		case "":
+
+		case "required":
+			if !exists || v.IsZero() {
+				msgs.Add("%s: required", pathName(path))
+			}
+
+		case "min", "max":
+			if !exists {
+				continue
+			}
+
+			cmp, err := compareWithLimit(v, arg)
+			if err != nil {
+				msgs.Add(`%s: bad rule "%s": %s`, pathName(path), rule, err)
+				continue
+			}
+
+			if name == "min" && cmp < 0 {
+				msgs.Add("%s: less than %s", pathName(path), arg)
+			} else if name == "max" && cmp > 0 {
+				msgs.Add("%s: greater than %s", pathName(path), arg)
+			}
+
+		case "oneof":
+			if !exists {
+				continue
+			}
+
+			s, _ := scalarText(reflect.Indirect(v))
+			found := false
+			for _, a := range strings.Fields(arg) {
+				if a == s {
+					found = true
+					break
+				}
+			}
+			if !found {
+				msgs.Add(`%s: "%s" is not one of %s`, pathName(path), s, strings.Join(strings.Fields(arg), ", "))
+			}
+
+		default:
+			msgs.Add(`%s: unknown validation rule "%s"`, pathName(path), rule)
+		}
+	}
+}
+
+// compareWithLimit -- -1, 0, 1 as v is less, equal or greater than the limit
+func compareWithLimit(v reflect.Value, limit string) (int, error) {
+	v = reflect.Indirect(v)
+
+	var a, b float64
+
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return 0, err
+		}
+		a, b = float64(v.Len()), float64(n)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		lv := reflect.New(v.Type()).Elem()
+		err := setTagValue(lv, limit)
+		if err != nil {
+			return 0, err
+		}
+		a, _ = toFloat64(v)
+		b, _ = toFloat64(lv)
+
+	default:
+		return 0, fmt.Errorf("not applicable to %s", v.Type())
+	}
+
+	switch {
+	case a < b:
+		return -1, nil
+	case a > b:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// setTagValue -- set the value from the tag string
+func setTagValue(v reflect.Value, s string) (err error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setTagValue(v.Elem(), s)
+	}
+
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerTp) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(s)
+		v.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		n, err = strconv.ParseInt(s, 10, v.Type().Bits())
+		v.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n uint64
+		n, err = strconv.ParseUint(s, 10, v.Type().Bits())
+		v.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(s, v.Type().Bits())
+		v.SetFloat(f)
+
+	case reflect.String:
+		v.SetString(s)
+
+	case reflect.Slice:
+		list := strings.Split(s, ",")
+		v.Set(reflect.MakeSlice(v.Type(), len(list), len(list)))
+		for i, e := range list {
+			err = setTagValue(v.Index(i), strings.TrimSpace(e))
+			if err != nil {
+				return
+			}
+		}
+
+	default:
+		err = fmt.Errorf("not applicable to %s", v.Type())
+	}
+
+	return
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+func srcMap(v reflect.Value) (map[string]reflect.Value, bool) {
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+
+	m := make(map[string]reflect.Value, v.Len())
+	for iter := v.MapRange(); iter.Next(); {
+		m[iter.Key().String()] = iter.Value()
+	}
+
+	return m, true
+}
+
+// scalarText -- text form of the scalar for encoding.TextUnmarshaler
+func scalarText(v reflect.Value) (string, bool) {
+	if v.Type() == timeTp {
+		return v.Interface().(time.Time).Format(time.RFC3339Nano), true
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), true
+	}
+
+	return "", false
+}
+
+func toInt64(v reflect.Value) (int64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		// JSON numbers
+		f := v.Float()
+		if f != math.Trunc(f) || f >= math.MaxInt64 || f < math.MinInt64 {
+			return 0, false
+		}
+		return int64(f), true
+	}
+
+	return 0, false
+}
+
+func toFloat64(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+
+	return 0, false
+}
+
+// kindName -- TOML name of the source value kind
+func kindName(v reflect.Value) string {
+	if v.Type() == timeTp {
+		return "datetime"
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return fmt.Sprintf(`string "%s"`, v.String())
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.Map, reflect.Struct:
+		return "table"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+
+	return v.Type().String()
+}
+
+func normKeyName(s string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(s))
+}
+
+func pathName(path string) string {
+	if path == "" {
+		return "<root>"
+	}
+	return path
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
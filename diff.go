@@ -0,0 +1,268 @@
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/naoina/toml"
+
+	"github.com/alrusov/misc"
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+type (
+	// Change -- difference between two configs. Values are in the text form, secrets are masked by the GetSecuredText rules
+	Change struct {
+		Path string `json:"path"` // toml names: "http.listener.auth.users.admin", "servers[1].addr"
+		Op   string `json:"op"`   // "added", "removed" or "changed"
+		Old  string `json:"old"`
+		New  string `json:"new"`
+
+		Reload string `json:"reload"` // ReloadLive or ReloadRestart by the reload tag of the nearest field
+	}
+)
+
+const (
+	// ChangeAdded --
+	ChangeAdded = "added"
+	// ChangeRemoved --
+	ChangeRemoved = "removed"
+	// ChangeChanged --
+	ChangeChanged = "changed"
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// Diff -- changes between two configs of the same type sorted by the path segments, indexes as numbers.
+// Structs are compared by the fields with toml names, maps by the keys, slices by the indexes.
+// Values with MarshalText (Duration, ByteSize etc.) and scalars are compared by the text
+func Diff(oldCfg any, newCfg any) []Change {
+	list := []Change{}
+	diffValues(&list, reflect.ValueOf(oldCfg), reflect.ValueOf(newCfg), nil, ReloadRestart)
+
+	segs := make(map[string][]pathSegment, len(list))
+	for _, c := range list {
+		segs[c.Path], _ = parsePath(c.Path)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return compareSegs(segs[list[i].Path], segs[list[j].Path]) < 0
+	})
+
+	return list
+}
+
+// DiffFiles -- changes between two config files after the full preprocessing, the files are compared as map[string]any
+func DiffFiles(oldFile string, newFile string) ([]Change, error) {
+	msgs := misc.NewMessages()
+	defer msgs.Free()
+
+	load := func(fileName string) (m map[string]any) {
+		data, err := Preprocess(fileName)
+		if err == nil {
+			err = toml.Unmarshal(data, &m)
+			if err != nil {
+				err = keyError(err, data, nil)
+			}
+		}
+		if err != nil {
+			msgs.Add("%s: %s", fileName, err)
+		}
+		return
+	}
+
+	oldCfg := load(oldFile)
+	newCfg := load(newFile)
+
+	if err := msgs.Error(); err != nil {
+		return nil, err
+	}
+
+	return Diff(oldCfg, newCfg), nil
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+func diffValues(list *[]Change, oldV reflect.Value, newV reflect.Value, segs []pathSegment, reload string) {
+	oldV = diffDeref(oldV)
+	newV = diffDeref(newV)
+
+	switch {
+	case !oldV.IsValid() && !newV.IsValid():
+		return
+	case !oldV.IsValid():
+		addChange(list, ChangeAdded, segs, oldV, newV, reload)
+		return
+	case !newV.IsValid():
+		addChange(list, ChangeRemoved, segs, oldV, newV, reload)
+		return
+	}
+
+	if oldV.Type() != newV.Type() || isTextValue(oldV.Type()) {
+		diffText(list, oldV, newV, segs, reload)
+		return
+	}
+
+	switch oldV.Kind() {
+	case reflect.Struct:
+		diffStructs(list, oldV, newV, segs, reload)
+
+	case reflect.Map:
+		if oldV.Type().Key().Kind() != reflect.String {
+			diffText(list, oldV, newV, segs, reload)
+			return
+		}
+
+		keys := map[string]reflect.Value{}
+		for _, k := range oldV.MapKeys() {
+			keys[k.String()] = k
+		}
+		for _, k := range newV.MapKeys() {
+			keys[k.String()] = k
+		}
+
+		names := make([]string, 0, len(keys))
+		for name := range keys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			k := keys[name]
+			diffValues(list, oldV.MapIndex(k), newV.MapIndex(k), appendSeg(segs, pathSegment{key: name}), reload)
+		}
+
+	case reflect.Slice, reflect.Array:
+		n := max(oldV.Len(), newV.Len())
+		for i := range n {
+			var o, v reflect.Value
+			if i < oldV.Len() {
+				o = oldV.Index(i)
+			}
+			if i < newV.Len() {
+				v = newV.Index(i)
+			}
+			diffValues(list, o, v, appendSeg(segs, pathSegment{index: i, isIndex: true}), reload)
+		}
+
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		// not comparable
+
+	default:
+		diffText(list, oldV, newV, segs, reload)
+	}
+}
+
+func diffStructs(list *[]Change, oldV reflect.Value, newV reflect.Value, segs []pathSegment, reload string) {
+	tp := oldV.Type()
+
+	for i := range tp.NumField() {
+		t := tp.Field(i)
+
+		_, tagged := t.Tag.Lookup("toml")
+		if t.Anonymous && !tagged && t.Type.Kind() == reflect.Struct {
+			// embedded fields are on the same level
+			diffStructs(list, oldV.Field(i), newV.Field(i), segs, fieldReload(&t, reload))
+			continue
+		}
+
+		if !t.IsExported() {
+			continue
+		}
+
+		name := misc.StructTagName(&t, "toml")
+		if name == "-" {
+			continue
+		}
+
+		diffValues(list, oldV.Field(i), newV.Field(i), appendSeg(segs, pathSegment{key: name}), fieldReload(&t, reload))
+	}
+}
+
+func diffText(list *[]Change, oldV reflect.Value, newV reflect.Value, segs []pathSegment, reload string) {
+	o, _ := valueText(oldV)
+	v, _ := valueText(newV)
+
+	if o != v {
+		addChange(list, ChangeChanged, segs, oldV, newV, reload)
+	}
+}
+
+func addChange(list *[]Change, op string, segs []pathSegment, oldV reflect.Value, newV reflect.Value, reload string) {
+	*list = append(*list,
+		Change{
+			Path: segsPath(segs),
+			Op:   op,
+			Old:  securedValue(segs, oldV),
+			New:  securedValue(segs, newV),
+
+			Reload: reload,
+		},
+	)
+}
+
+// diffDeref -- nil pointers and interfaces are the absent values
+func diffDeref(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+
+	return v
+}
+
+// isTextValue -- the value is compared as a whole by its text
+func isTextValue(tp reflect.Type) bool {
+	return tp.Implements(textMarshalerTp) || reflect.PointerTo(tp).Implements(textMarshalerTp) || tp == timeTp
+}
+
+// compareSegs -- segment-wise order of the paths, indexes are compared as numbers: servers[2] is before servers[10]
+func compareSegs(a []pathSegment, b []pathSegment) int {
+	for i := range min(len(a), len(b)) {
+		x, y := a[i], b[i]
+
+		switch {
+		case x.isIndex && y.isIndex:
+			if c := cmp.Compare(x.index, y.index); c != 0 {
+				return c
+			}
+		case x.isIndex != y.isIndex:
+			if x.isIndex {
+				return -1
+			}
+			return 1
+		default:
+			if c := strings.Compare(x.key, y.key); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return cmp.Compare(len(a), len(b))
+}
+
+func appendSeg(segs []pathSegment, seg pathSegment) []pathSegment {
+	return append(append(make([]pathSegment, 0, len(segs)+1), segs...), seg)
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// String --
+func (c Change) String() string {
+	switch c.Op {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s = %s", c.Path, c.New)
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s = %s", c.Path, c.Old)
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", c.Path, c.Old, c.New)
+	}
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
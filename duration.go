@@ -1,6 +1,12 @@
 package config
 
 import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/alrusov/misc"
@@ -12,11 +18,98 @@ type (
 	Duration time.Duration
 )
 
+var (
+	durationNumberUnitMutex = new(sync.RWMutex)
+	durationNumberUnit      = time.Second
+
+	// P[nY][nM][nW][nD][T[nH][nM][nS]], the fractional part is allowed in all elements
+	reISO8601Duration = regexp.MustCompile(`^([+-])?P(?:(\d+(?:[.,]\d+)?)Y)?(?:(\d+(?:[.,]\d+)?)M)?(?:(\d+(?:[.,]\d+)?)W)?(?:(\d+(?:[.,]\d+)?)D)?(?:T(?:(\d+(?:[.,]\d+)?)H)?(?:(\d+(?:[.,]\d+)?)M)?(?:(\d+(?:[.,]\d+)?)S)?)?$`)
+
+	iso8601Units = []time.Duration{
+		0, // sign
+		365 * 24 * time.Hour,
+		30 * 24 * time.Hour,
+		7 * 24 * time.Hour,
+		24 * time.Hour,
+		time.Hour,
+		time.Minute,
+		time.Second,
+	}
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// SetDurationNumberUnit -- unit of the numeric Duration values (TOML integers and floats, strings without units), time.Second by default.
+// It is the setting of the whole process, not of one config: set it by the application once before LoadFile, not by the libraries
+func SetDurationNumberUnit(unit time.Duration) error {
+	if unit <= 0 {
+		return fmt.Errorf(`bad duration unit %s`, unit)
+	}
+
+	durationNumberUnitMutex.Lock()
+	defer durationNumberUnitMutex.Unlock()
+
+	durationNumberUnit = unit
+	return nil
+}
+
+// DurationNumberUnit -- unit of the numeric Duration values
+func DurationNumberUnit() time.Duration {
+	durationNumberUnitMutex.RLock()
+	defer durationNumberUnitMutex.RUnlock()
+
+	return durationNumberUnit
+}
+
 //----------------------------------------------------------------------------------------------------------------------------//
 
+// UnmarshalTOML implements toml.UnmarshalerRec. Accepts integers and floats in DurationNumberUnit,
+// strings and local time values (hh:mm:ss as a time since midnight)
+func (d *Duration) UnmarshalTOML(decode func(any) error) error {
+	var v any
+	err := decode(&v)
+	if err != nil {
+		return err
+	}
+
+	var duration time.Duration
+
+	switch v := v.(type) {
+	case int64:
+		u := int64(DurationNumberUnit())
+		if v != 0 && (v*u)/v != u {
+			return fmt.Errorf(`bad duration %d: out of range`, v)
+		}
+		duration = time.Duration(v * u)
+
+	case float64:
+		duration, err = number2Duration(v, strconv.FormatFloat(v, 'g', -1, 64))
+
+	case string:
+		duration, err = ParseDuration(v)
+
+	case time.Time:
+		if v.Year() != 0 || v.Month() != time.January || v.Day() != 1 {
+			return fmt.Errorf(`bad duration "%s": only the local time is allowed`, v.Format(time.RFC3339))
+		}
+		duration = time.Duration(v.Hour())*time.Hour + time.Duration(v.Minute())*time.Minute + time.Duration(v.Second())*time.Second +
+			time.Duration(v.Nanosecond())
+
+	default:
+		return fmt.Errorf(`bad duration "%v": unsupported type %T`, v, v)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(duration)
+	return nil
+}
+
 // UnmarshalText implements encoding.TextUnmarshaler
 func (d *Duration) UnmarshalText(data []byte) error {
-	duration, err := misc.Interval2Duration(string(data))
+	duration, err := ParseDuration(string(data))
 	if err == nil {
 		*d = Duration(duration)
 	}
@@ -25,7 +118,91 @@ func (d *Duration) UnmarshalText(data []byte) error {
 
 // MarshalText implements encoding.TextMarshaler
 func (d Duration) MarshalText() ([]byte, error) {
-	return []byte(misc.Int2Interval(int64(d))), nil
+	switch {
+	case d == math.MinInt64:
+		// cannot be negated
+		return []byte(time.Duration(d).String()), nil
+	case d < 0:
+		return []byte("-" + misc.Int2Interval(-int64(d))), nil
+	default:
+		return []byte(misc.Int2Interval(int64(d))), nil
+	}
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// ParseDuration -- parse the duration in one of the forms:
+//
+//	"1d2h3m4s5ms6us7ns"   misc.Interval2Duration
+//	"1.5h", "300µs"       time.ParseDuration
+//	"P1DT2H", "PT0.5S"    ISO 8601 (a year is 365 days, a month is 30 days)
+//	"25", "0.5"           a number in DurationNumberUnit
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err == nil {
+		return number2Duration(f, s)
+	}
+
+	duration, err := misc.Interval2Duration(s)
+	if err == nil {
+		return duration, nil
+	}
+
+	duration, err = time.ParseDuration(s)
+	if err == nil {
+		return duration, nil
+	}
+
+	if strings.ContainsAny(s, "Pp") {
+		return parseISO8601Duration(s)
+	}
+
+	return 0, fmt.Errorf(`bad duration "%s" (expected something like "1d2h", "1.5h", "P1DT2H" or a number of %s)`, s, DurationNumberUnit())
+}
+
+func number2Duration(v float64, src string) (time.Duration, error) {
+	v *= float64(DurationNumberUnit())
+	if math.IsNaN(v) || v >= math.MaxInt64 || v < math.MinInt64 {
+		return 0, fmt.Errorf(`bad duration %s: out of range`, src)
+	}
+
+	return time.Duration(math.Round(v)), nil
+}
+
+func parseISO8601Duration(s string) (time.Duration, error) {
+	m := reISO8601Duration.FindStringSubmatch(strings.ToUpper(s))
+	if m == nil || strings.HasSuffix(m[0], "P") || strings.HasSuffix(m[0], "T") {
+		return 0, fmt.Errorf(`bad ISO 8601 duration "%s"`, s)
+	}
+
+	v := 0.
+	for i := 2; i < len(m); i++ {
+		if m[i] == "" {
+			continue
+		}
+
+		n, err := strconv.ParseFloat(strings.Replace(m[i], ",", ".", 1), 64)
+		if err != nil {
+			return 0, fmt.Errorf(`bad ISO 8601 duration "%s": %s`, s, err)
+		}
+
+		v += n * float64(iso8601Units[i-1])
+	}
+
+	if v >= math.MaxInt64 {
+		return 0, fmt.Errorf(`bad ISO 8601 duration "%s": out of range`, s)
+	}
+
+	if m[1] == "-" {
+		v = -v
+	}
+
+	return time.Duration(math.Round(v)), nil
 }
 
 //----------------------------------------------------------------------------------------------------------------------------//
@@ -0,0 +1,287 @@
+package config
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/alrusov/misc"
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+type (
+	// EndpointMatcher -- compiled list of endpoint patterns
+	//
+	// Every pattern is one of:
+	//   /path        -- exact path
+	//   /path/*/x    -- glob, "*" matches any characters within one path segment, "?" matches one character
+	//   /path*       -- "*" at the end matches the rest of the path including "/", it is the prefix as before
+	//   /path/**     -- glob, "**" matches any characters including "/"
+	//   ~^/api/v\d+/ -- regular expression (everything after "~")
+	// The "!" prefix makes the pattern an exception: a path that matches any exception never matches the list.
+	EndpointMatcher struct {
+		patterns   []*endpointPattern
+		exceptions []*endpointPattern
+	}
+
+	endpointPattern struct {
+		src   string
+		exact string
+		re    *regexp.Regexp
+	}
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// NewEndpointMatcher --
+func NewEndpointMatcher(list []string) (m *EndpointMatcher, err error) {
+	msgs := misc.NewMessages()
+	defer msgs.Free()
+
+	m = &EndpointMatcher{
+		patterns:   make([]*endpointPattern, 0, len(list)),
+		exceptions: make([]*endpointPattern, 0),
+	}
+
+	for _, src := range list {
+		s := strings.TrimSpace(src)
+
+		negative := strings.HasPrefix(s, "!")
+		if negative {
+			s = strings.TrimSpace(s[1:])
+		}
+
+		if s == "" {
+			msgs.Add(`empty pattern "%s"`, src)
+			continue
+		}
+
+		p, err := compileEndpointPattern(s)
+		if err != nil {
+			msgs.Add(`pattern "%s": %s`, src, err)
+			continue
+		}
+
+		if negative {
+			m.exceptions = append(m.exceptions, p)
+		} else {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+
+	err = msgs.Error()
+	return
+}
+
+func compileEndpointPattern(s string) (p *endpointPattern, err error) {
+	p = &endpointPattern{
+		src: s,
+	}
+
+	if s[0] == '~' {
+		p.re, err = regexp.Compile(s[1:])
+		return
+	}
+
+	s = misc.NormalizeSlashes("/" + s)
+	p.src = s
+
+	if !strings.ContainsAny(s, "*?") {
+		p.exact = s
+		return
+	}
+
+	b := new(strings.Builder)
+	b.WriteByte('^')
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '*':
+			if i+1 < len(s) && s[i+1] == '*' {
+				i++
+				if i+1 < len(s) && s[i+1] == '/' {
+					// "/**/" also matches "/"
+					i++
+					b.WriteString(`(?:.*/)?`)
+					continue
+				}
+				b.WriteString(`.*`)
+				continue
+			}
+			if i == len(s)-1 {
+				// the trailing "*" is the prefix match
+				b.WriteString(`.*`)
+				continue
+			}
+			b.WriteString(`[^/]*`)
+		case '?':
+			b.WriteString(`[^/]`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	b.WriteByte('$')
+
+	p.re, err = regexp.Compile(b.String())
+	return
+}
+
+func (p *endpointPattern) match(path string) bool {
+	if p.re != nil {
+		return p.re.MatchString(path)
+	}
+	return p.exact == path
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// Match -- the path matches at least one pattern and none of the exceptions
+func (m *EndpointMatcher) Match(path string) bool {
+	if m == nil {
+		return false
+	}
+
+	path = misc.NormalizeSlashes("/" + path)
+
+	found := false
+	for _, p := range m.patterns {
+		if p.match(path) {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return false
+	}
+
+	for _, p := range m.exceptions {
+		if p.match(path) {
+			return false
+		}
+	}
+
+	return true
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// IsEndpointDisabled --
+func (x *Listener) IsEndpointDisabled(path string) bool {
+	if x.disabledEndpoints == nil {
+		// Check was not called
+		return x.DisabledEndpoints[misc.NormalizeSlashes(path)]
+	}
+
+	return x.disabledEndpoints.Match(path)
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// NormalizeEndpointPath -- the normalized form of the endpoint path used as a key. Regular expressions are kept as is
+func NormalizeEndpointPath(path string) string {
+	path = strings.TrimSpace(path)
+	if strings.HasPrefix(path, "~") {
+		return path
+	}
+	return misc.NormalizeSlashes("/" + path)
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+type (
+	// EndpointTable -- values by endpoint paths and patterns. The exact path has priority, then the longest (most specific) pattern wins
+	EndpointTable[T any] struct {
+		exact    map[string]T
+		patterns []*endpointTableEntry[T]
+	}
+
+	endpointTableEntry[T any] struct {
+		pattern *endpointPattern
+		value   T
+	}
+)
+
+// NewEndpointTable -- src keys must be normalized by NormalizeEndpointPath
+func NewEndpointTable[T any](src map[string]T) (t *EndpointTable[T], err error) {
+	msgs := misc.NewMessages()
+	defer msgs.Free()
+
+	t = &EndpointTable[T]{
+		exact:    make(map[string]T, len(src)),
+		patterns: make([]*endpointTableEntry[T], 0),
+	}
+
+	for path, v := range src {
+		if path == "" || !(path[0] == '~' || strings.ContainsAny(path, "*?")) {
+			t.exact[path] = v
+			continue
+		}
+
+		p, err := compileEndpointPattern(path)
+		if err != nil {
+			msgs.Add(`endpoint "%s": %s`, path, err)
+			continue
+		}
+
+		t.patterns = append(t.patterns,
+			&endpointTableEntry[T]{
+				pattern: p,
+				value:   v,
+			},
+		)
+	}
+
+	sort.Slice(t.patterns,
+		func(i, j int) bool {
+			pi, pj := t.patterns[i].pattern.src, t.patterns[j].pattern.src
+			if len(pi) != len(pj) {
+				return len(pi) > len(pj)
+			}
+			return pi < pj
+		},
+	)
+
+	err = msgs.Error()
+	return
+}
+
+// Find --
+func (t *EndpointTable[T]) Find(path string) (v T, found bool) {
+	if t == nil {
+		return
+	}
+
+	path = misc.NormalizeSlashes("/" + path)
+
+	v, found = t.exact[path]
+	if found {
+		return
+	}
+
+	for _, p := range t.patterns {
+		if p.pattern.match(path) {
+			return p.value, true
+		}
+	}
+
+	return
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// FindEndpoint -- get the users/groups list for the path. The exact path has priority over patterns
+func (x *Auth) FindEndpoint(path string) (users misc.BoolMap, found bool) {
+	if x.endpoints == nil {
+		// Check was not called
+		users, found = x.Endpoints[misc.NormalizeSlashes("/"+path)]
+		return
+	}
+
+	return x.endpoints.Find(path)
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
@@ -1,22 +1,15 @@
 package config
 
 import (
-	"bytes"
 	"fmt"
 	"reflect"
-
-	"github.com/naoina/toml"
-)
-
-//----------------------------------------------------------------------------------------------------------------------------//
-
-var (
-	imapTp = reflect.ValueOf(map[string]any{}).Type()
 )
 
 //----------------------------------------------------------------------------------------------------------------------------//
 
-func ConvExtra(src *any, obj any) (err error) {
+// ConvExtra -- decode the free-form *src (map[string]any, misc.InterfaceMap etc.) into obj, then *src is replaced by obj.
+// See DecodeExtra for the options and the tags
+func ConvExtra(src *any, obj any, opts ...DecodeOption) (err error) {
 	if src == nil {
 		return fmt.Errorf(`src is nil`)
 	}
@@ -25,37 +18,19 @@ func ConvExtra(src *any, obj any) (err error) {
 		return fmt.Errorf(`obj is nil`)
 	}
 
-	if reflect.ValueOf(obj).Kind() != reflect.Ptr {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
 		return fmt.Errorf(`obj is not a pointer`)
 	}
 
-	if *src == nil {
-		*src = obj
+	if *src != nil && reflect.TypeOf(*src) == v.Type() {
+		// already converted
 		return
 	}
 
-	srcTp := reflect.ValueOf(*src).Type()
-	if srcTp != imapTp {
-		return fmt.Errorf(`src is "%s", expected "%s"`, srcTp, imapTp)
-	}
-
-	buf := new(bytes.Buffer)
-
-	err = toml.NewEncoder(buf).Encode(*src)
+	err = decodeExtra(v.Elem(), *src, opts...)
 	if err != nil {
-		return fmt.Errorf(`encode error: %w`, err)
-	}
-
-	objTp := reflect.ValueOf(obj).Type()
-
-	err = toml.NewDecoder(buf).Decode(obj)
-	if err != nil {
-		return fmt.Errorf(`decode error: %w`, err)
-	}
-
-	newObjTp := reflect.ValueOf(obj).Type()
-	if newObjTp != objTp {
-		return fmt.Errorf(`converted data is "%s", expected "%s"`, newObjTp, objTp)
+		return
 	}
 
 	*src = obj
@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 )
 
 //----------------------------------------------------------------------------------------------------------------------------//
@@ -53,3 +55,203 @@ func GetBlock[T any](cfg any, name string) (*T, error) {
 }
 
 //----------------------------------------------------------------------------------------------------------------------------//
+
+// Lookup -- the value by the path of toml names: "http.listener.auth.methods.jwt", "servers[2].addr", `users."name.with.dots"`.
+// Nested structs, pointers, interfaces, maps with string keys, slices and arrays are traversed.
+// T may be the type of the value or a pointer to it (the value must be addressable or be a pointer)
+func Lookup[T any](cfg any, path string) (result T, err error) {
+	v, err := lookupValue(reflect.ValueOf(cfg), path)
+	if err != nil {
+		return
+	}
+
+	tp := reflect.TypeOf((*T)(nil)).Elem()
+
+	for {
+		if v.Type().AssignableTo(tp) {
+			return v.Interface().(T), nil
+		}
+
+		if v.CanAddr() && v.Addr().Type().AssignableTo(tp) {
+			return v.Addr().Interface().(T), nil
+		}
+
+		if (v.Kind() != reflect.Pointer && v.Kind() != reflect.Interface) || v.IsNil() {
+			break
+		}
+
+		v = v.Elem()
+	}
+
+	err = fmt.Errorf("%s: %s is not compatible with %s", pathName(path), v.Type(), tp)
+	return
+}
+
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parsePath -- split the path to the keys and the indexes
+func parsePath(path string) (list []pathSegment, err error) {
+	s := strings.TrimSpace(path)
+
+	for s != "" {
+		switch s[0] {
+		case '.':
+			if len(list) == 0 || len(s) == 1 || s[1] == '.' || s[1] == '[' {
+				return nil, fmt.Errorf(`bad path "%s": empty key`, path)
+			}
+			s = s[1:]
+			continue
+
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf(`bad path "%s": "]" not found`, path)
+			}
+
+			n, e := strconv.Atoi(strings.TrimSpace(s[1:end]))
+			if e != nil || n < 0 {
+				return nil, fmt.Errorf(`bad path "%s": bad index "%s"`, path, s[1:end])
+			}
+
+			list = append(list, pathSegment{index: n, isIndex: true})
+			s = s[end+1:]
+			continue
+
+		case '"':
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf(`bad path "%s": unterminated quoted key`, path)
+			}
+
+			list = append(list, pathSegment{key: s[1 : end+1]})
+			s = s[end+2:]
+
+		default:
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+
+			list = append(list, pathSegment{key: strings.TrimSpace(s[:end])})
+			s = s[end:]
+		}
+
+		if s != "" && s[0] != '.' && s[0] != '[' {
+			return nil, fmt.Errorf(`bad path "%s": "." or "[" expected before "%s"`, path, s)
+		}
+	}
+
+	return
+}
+
+// lookupValue -- the value by the path, addressable if it is possible
+func lookupValue(v reflect.Value, path string) (reflect.Value, error) {
+	list, err := parsePath(path)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	return lookupSegs(v, list)
+}
+
+// lookupSegs -- the value by the parsed path
+func lookupSegs(v reflect.Value, list []pathSegment) (reflect.Value, error) {
+	done := ""
+
+	for _, seg := range list {
+		for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return reflect.Value{}, fmt.Errorf("%s: is nil", pathName(done))
+			}
+			v = v.Elem()
+		}
+
+		if seg.isIndex {
+			done = fmt.Sprintf("%s[%d]", done, seg.index)
+
+			if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+				return reflect.Value{}, fmt.Errorf("%s: %s is not an array", done, v.Type())
+			}
+
+			if seg.index >= v.Len() {
+				return reflect.Value{}, fmt.Errorf("%s: index %d is out of range, length is %d", done, seg.index, v.Len())
+			}
+
+			v = v.Index(seg.index)
+			continue
+		}
+
+		done = joinPath(done, seg.key)
+
+		switch v.Kind() {
+		case reflect.Struct:
+			f, found := structFieldByKey(v, seg.key)
+			if !found {
+				return reflect.Value{}, fmt.Errorf(`%s: %s has no field with the key "%s"`, done, v.Type(), seg.key)
+			}
+			v = f
+
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return reflect.Value{}, fmt.Errorf("%s: %s keys are not strings", done, v.Type())
+			}
+
+			e := v.MapIndex(reflect.ValueOf(seg.key).Convert(v.Type().Key()))
+			if !e.IsValid() {
+				return reflect.Value{}, fmt.Errorf(`%s: key "%s" not found`, done, seg.key)
+			}
+			v = e
+
+		default:
+			return reflect.Value{}, fmt.Errorf("%s: %s has no keys", done, v.Type())
+		}
+	}
+
+	if !v.IsValid() {
+		return reflect.Value{}, fmt.Errorf("%s: is nil", pathName(done))
+	}
+
+	return v, nil
+}
+
+// structFieldByKey -- the field by the toml name, untagged fields are matched ignoring case, "_" and "-". Embedded structs are searched too
+func structFieldByKey(v reflect.Value, key string) (reflect.Value, bool) {
+	tp := v.Type()
+
+	for i := range tp.NumField() {
+		t := tp.Field(i)
+
+		tag, tagged := t.Tag.Lookup("toml")
+		if t.Anonymous && !tagged && t.Type.Kind() == reflect.Struct {
+			f, found := structFieldByKey(v.Field(i), key)
+			if found {
+				return f, true
+			}
+			continue
+		}
+
+		if !t.IsExported() {
+			continue
+		}
+
+		if tagged {
+			name, _, _ := strings.Cut(tag, ",")
+			if name == key && name != "-" {
+				return v.Field(i), true
+			}
+			continue
+		}
+
+		if normKeyName(t.Name) == normKeyName(key) {
+			return v.Field(i), true
+		}
+	}
+
+	return reflect.Value{}, false
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
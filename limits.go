@@ -0,0 +1,150 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/alrusov/misc"
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+type (
+	// ListenerLimits --
+	ListenerLimits struct {
+		// Maximum number of simultaneous connections, 0 - unlimited
+		MaxConnections int `toml:"max-connections"`
+
+		// Defaults for all endpoints
+		Default EndpointLimit `toml:"default"`
+
+		// Overrides by endpoint paths and patterns, the same syntax as in auth.endpoints
+		Endpoints map[string]EndpointLimit `toml:"endpoints"`
+
+		table *EndpointTable[EndpointLimit]
+	}
+
+	// EndpointLimit -- 0 in all fields means unlimited
+	EndpointLimit struct {
+		RequestsPerSecond Rate `toml:"requests-per-second"` // per client IP
+		Burst             int  `toml:"burst"`               // RequestsPerSecond rounded up by default
+		MaxConcurrent     int  `toml:"max-concurrent"`      // simultaneous requests
+	}
+
+	// Rate -- events per second, integer or float: 10, 2.5
+	Rate float64
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// Check --
+func (x *ListenerLimits) Check(cfg any) (err error) {
+	msgs := misc.NewMessages()
+	defer msgs.Free()
+
+	if x.MaxConnections < 0 {
+		msgs.Add("max-connections: negative value %d", x.MaxConnections)
+	}
+
+	err = x.Default.check()
+	if err != nil {
+		msgs.Add("default: %s", err)
+	}
+
+	var endpoints map[string]EndpointLimit
+	if x.Endpoints != nil {
+		endpoints = make(map[string]EndpointLimit, len(x.Endpoints))
+	}
+
+	for path, limit := range x.Endpoints {
+		name := NormalizeEndpointPath(path)
+
+		_, exists := endpoints[name]
+		if exists {
+			msgs.Add(`endpoints: "%s" is duplicated`, path)
+			continue
+		}
+
+		err = limit.check()
+		if err != nil {
+			msgs.Add(`endpoints."%s": %s`, path, err)
+			continue
+		}
+
+		endpoints[name] = limit
+	}
+
+	x.Endpoints = endpoints
+
+	x.table, err = NewEndpointTable(x.Endpoints)
+	if err != nil {
+		msgs.Add("endpoints: %s", err)
+	}
+
+	return msgs.Error()
+}
+
+func (x *EndpointLimit) check() (err error) {
+	msgs := misc.NewMessages()
+	defer msgs.Free()
+
+	if x.RequestsPerSecond < 0 {
+		msgs.Add("requests-per-second: negative value %g", x.RequestsPerSecond)
+	}
+
+	switch {
+	case x.Burst < 0:
+		msgs.Add("burst: negative value %d", x.Burst)
+	case x.Burst == 0 && x.RequestsPerSecond > 0:
+		x.Burst = int(x.RequestsPerSecond)
+		if Rate(x.Burst) < x.RequestsPerSecond {
+			x.Burst++
+		}
+	}
+
+	if x.MaxConcurrent < 0 {
+		msgs.Add("max-concurrent: negative value %d", x.MaxConcurrent)
+	}
+
+	return msgs.Error()
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// UnmarshalTOML implements toml.UnmarshalerRec, integers are accepted as well as floats
+func (x *Rate) UnmarshalTOML(decode func(any) error) error {
+	var v any
+	err := decode(&v)
+	if err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case int64:
+		*x = Rate(v)
+	case float64:
+		*x = Rate(v)
+	default:
+		return fmt.Errorf(`bad rate "%v": number expected`, v)
+	}
+
+	return nil
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// Lookup -- limits for the path: the endpoint override if it exists or the defaults
+func (x *ListenerLimits) Lookup(path string) EndpointLimit {
+	limit, found := x.table.Find(path)
+	if found {
+		return limit
+	}
+
+	return x.Default
+}
+
+// IsUnlimited --
+func (x EndpointLimit) IsUnlimited() bool {
+	return x.RequestsPerSecond == 0 && x.MaxConcurrent == 0
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
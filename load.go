@@ -11,9 +11,11 @@ import (
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
+	"unicode"
 
 	"github.com/naoina/toml"
 
@@ -25,9 +27,19 @@ import (
 
 var (
 	configText     = ""
+	configDir      = "" // directory of the main config file, the base for the Path values
 	fullConfig     = any(nil)
 	commonConfig   *Common
 	listenerConfig *Listener
+	listeners      = map[string]*Listener{}
+	dbs            = map[string]*DB{}
+	dbRefs         = map[string]string{} // toml path -> referenced DB name
+	mapBlocks      = map[string]any{}    // DB and Listener blocks found in maps, Check of the application can't reach them
+	mapValues      = []mapValue{}        // addressable copies of the struct values of maps with the standard blocks
+
+	commonTp   = reflect.TypeOf(Common{})
+	listenerTp = reflect.TypeOf(Listener{})
+	dbTp       = reflect.TypeOf(DB{})
 
 	rePreprocessor = regexp.MustCompile(`(\$\{|\{\$|\{#|\{@)([^\}]+)(?:\})`)
 
@@ -53,6 +65,12 @@ var (
 	}
 )
 
+type mapValue struct {
+	m reflect.Value
+	k reflect.Value
+	v reflect.Value
+}
+
 //----------------------------------------------------------------------------------------------------------------------------//
 
 var embedFS *embed.FS
@@ -61,10 +79,19 @@ func Embed(fs *embed.FS) {
 	embedFS = fs
 }
 
+// execDir -- used instead of the application directory for the relative file names without prefixes
+var execDir = ""
+
+// SetExecDir -- the directory used instead of the directory of the executable for the relative file names without the @, $ and ^ prefixes.
+// For the tools checking the config files of another application
+func SetExecDir(dir string) {
+	execDir = dir
+}
+
 //----------------------------------------------------------------------------------------------------------------------------//
 
-func readFile(name string, base string, mandatory bool) ([]byte, string, error) {
-	var err error
+// readFile -- lines is the line number in the file of every line of data, comments and continuations are removed from data
+func readFile(name string, base string, mandatory bool) (data []byte, lines []int, fn string, err error) {
 	f := fs.File(nil)
 
 	if embedFS != nil {
@@ -74,58 +101,170 @@ func readFile(name string, base string, mandatory bool) ([]byte, string, error)
 	if f == nil {
 		// embedFS is nil or the embedded file was not found - let's try reading from the file system
 
+		if execDir != "" && name != "" && !filepath.IsAbs(name) && !strings.ContainsAny(name[0:1], "@$^") {
+			name = filepath.Join(execDir, name)
+		}
+
 		name, err = misc.AbsPathEx(name, base)
 		if err != nil {
-			return nil, "", err
+			return nil, nil, "", err
 		}
 
 		f, err = os.Open(name)
 
 		if err != nil {
 			if mandatory {
-				return nil, name, err
+				return nil, nil, name, err
 			}
 
 			log.Message(log.NOTICE, "Included file %s not found", name)
-			return nil, name, nil
+			return nil, nil, name, nil
 		}
 	}
 
 	defer f.Close()
 
-	data, err := io.ReadAll(f)
+	raw, err := io.ReadAll(f)
 	if err != nil {
-		return nil, name, nil
+		return nil, nil, name, nil
+	}
+
+	// offsets in raw of every byte of data
+	data = raw
+	offs := make([]int, len(raw))
+	for i := range offs {
+		offs[i] = i
 	}
 
-	data = bytes.TrimSpace(reComment.ReplaceAll(data, []byte{}))
-	data = bytes.TrimSpace(reMultiLine.ReplaceAll(data, []byte{' '}))
+	data, offs = replaceTracked(data, offs, reComment, []byte{})
+	data, offs = trimSpaceTracked(data, offs)
+	data, offs = replaceTracked(data, offs, reMultiLine, []byte{' '})
+	data, offs = trimSpaceTracked(data, offs)
 	data = bytes.TrimRight(data, "\\")
+	offs = offs[:len(data)]
+
+	return data, lineNumbers(raw, data, offs), name, nil
+}
+
+// replaceTracked -- ReplaceAll with the offsets of the bytes moved with them. The replacement gets the offset of the match
+func replaceTracked(data []byte, offs []int, re *regexp.Regexp, repl []byte) ([]byte, []int) {
+	newData := make([]byte, 0, len(data))
+	newOffs := make([]int, 0, len(offs))
+	prev := 0
+
+	for _, m := range re.FindAllIndex(data, -1) {
+		newData = append(newData, data[prev:m[0]]...)
+		newOffs = append(newOffs, offs[prev:m[0]]...)
+
+		off := 0
+		if m[0] < len(offs) {
+			off = offs[m[0]]
+		} else if len(offs) != 0 {
+			off = offs[len(offs)-1]
+		}
 
-	return data, name, nil
+		newData = append(newData, repl...)
+		for range repl {
+			newOffs = append(newOffs, off)
+		}
+
+		prev = m[1]
+	}
+
+	newData = append(newData, data[prev:]...)
+	newOffs = append(newOffs, offs[prev:]...)
+
+	return newData, newOffs
+}
+
+func trimSpaceTracked(data []byte, offs []int) ([]byte, []int) {
+	start := len(data) - len(bytes.TrimLeftFunc(data, unicode.IsSpace))
+	data = bytes.TrimSpace(data)
+	return data, offs[start : start+len(data)]
+}
+
+// lineNumbers -- line number in raw of every line of data
+func lineNumbers(raw []byte, data []byte, offs []int) []int {
+	lineOf := make([]int, len(raw))
+	n := 1
+	for i, c := range raw {
+		lineOf[i] = n
+		if c == '\n' {
+			n++
+		}
+	}
+
+	lines := make([]int, 0, bytes.Count(data, []byte("\n"))+1)
+	start := 0
+	for {
+		line := 0
+		if start < len(offs) {
+			line = lineOf[offs[start]]
+		}
+		lines = append(lines, line)
+
+		i := bytes.IndexByte(data[start:], '\n')
+		if i < 0 {
+			break
+		}
+		start += i + 1
+	}
+
+	return lines
 }
 
 // ----------------------------------------------------------------------------------------------------------------------------//
 
+type (
+	// Include -- node of the include tree
+	Include struct {
+		File      string     `json:"file"`
+		Mandatory bool       `json:"mandatory"` // {#include ...}, {##include ...} is optional
+		Missing   bool       `json:"missing"`
+		Includes  []*Include `json:"includes,omitempty"`
+	}
+
+	// PreprocessInfo -- result of PreprocessFile
+	PreprocessInfo struct {
+		Text     []byte          // as GetText returns it after LoadFile
+		File     string          // absolute name of the main file
+		Sources  []string        // source file of every line of Text
+		Lines    []int           // line number in the source file of every line of Text
+		Includes *Include        // the main file is the root
+		EnvRefs  map[string]bool // referenced environment variables -> defined
+	}
+)
+
 type populate struct {
 	lineNumber uint
 	macroses   map[string][]byte
+	sources    []string        // source file of every output line
+	lines      []int           // line number in the source file of every output line
+	node       *Include        // file being processed
+	envRefs    map[string]bool // referenced environment variables -> defined
 }
 
-func (populate *populate) do(data []byte, base string) (newData *bytes.Buffer, withWarn bool, err error) {
+func (populate *populate) do(data []byte, lines []int, fn string) (newData *bytes.Buffer, withWarn bool, err error) {
 	newData = new(bytes.Buffer)
 	withWarn = false
 
+	base := filepath.Dir(fn)
+
 	msgs := misc.NewMessages()
 	defer msgs.Free()
 
 	list := bytes.Split(data, []byte("\n"))
 
-	for _, line := range list {
+	for i, line := range list {
 		line = bytes.TrimSpace(line)
 		if len(line) == 0 {
 			continue
 		}
+
+		srcLine := 0
+		if i < len(lines) {
+			srcLine = lines[i]
+		}
 		if line[0] == '#' {
 			continue
 		}
@@ -150,6 +289,7 @@ func (populate *populate) do(data []byte, base string) (newData *bytes.Buffer, w
 		}
 
 		nIter := 0
+		included := 0 // lines of the included files are already registered in the sources
 
 		for {
 			nIter++
@@ -168,6 +308,7 @@ func (populate *populate) do(data []byte, base string) (newData *bytes.Buffer, w
 				case "${", "{$":
 					name := string(matches[2])
 					v, exists := env[name]
+					populate.envRefs[name] = exists
 					if !exists {
 						withWarn = true
 						log.Message(log.WARNING, `Undefined environment variable "%s" in line %d, using empty value`, name, populate.lineNumber)
@@ -194,13 +335,26 @@ func (populate *populate) do(data []byte, base string) (newData *bytes.Buffer, w
 							msgs.Add(`Illegal preprocessor command "%s" in line %d`, string(matches[2]), populate.lineNumber)
 						} else {
 							var err error
-							repl, fn, err := readFile(p[1], base, mandatory)
+							repl, replLines, fn, err := readFile(p[1], base, mandatory)
+
+							node := &Include{
+								File:      fn,
+								Mandatory: mandatory,
+								Missing:   err != nil || repl == nil,
+							}
+							populate.node.Includes = append(populate.node.Includes, node)
+
 							if err != nil {
 								msgs.Add(`Include error "%s" in line %d`, err.Error(), populate.lineNumber)
 							} else {
 								populate.lineNumber--
 								w := false
-								b, w, err = populate.do(repl, filepath.Dir(fn))
+								n := len(populate.sources)
+								parent := populate.node
+								populate.node = node
+								b, w, err = populate.do(repl, replLines, fn)
+								populate.node = parent
+								included += len(populate.sources) - n
 								if w {
 									withWarn = true
 								}
@@ -219,8 +373,14 @@ func (populate *populate) do(data []byte, base string) (newData *bytes.Buffer, w
 			}
 		}
 
-		newData.Write(bytes.TrimSpace(line))
+		line = bytes.TrimSpace(line)
+		newData.Write(line)
 		newData.WriteByte(byte('\n'))
+
+		for n := bytes.Count(line, []byte("\n")) + 1 - included; n > 0; n-- {
+			populate.sources = append(populate.sources, fn)
+			populate.lines = append(populate.lines, srcLine)
+		}
 	}
 
 	err = msgs.Error()
@@ -230,20 +390,107 @@ func (populate *populate) do(data []byte, base string) (newData *bytes.Buffer, w
 
 //----------------------------------------------------------------------------------------------------------------------------//
 
-// LoadFile parses the specified file into a Config object
-func LoadFile(fileName string, cfg any) (err error) {
+// preprocess -- read the file and apply the preprocessor. fn is the absolute file name
+func preprocess(fileName string) (p *populate, fn string, newData *bytes.Buffer, withWarn bool, err error) {
 	if len(env) == 0 {
 		loadEnv()
 	}
 
-	data, fn, err := readFile(fileName, misc.AppWorkDir(), true)
+	data, lines, fn, err := readFile(fileName, misc.AppWorkDir(), true)
 	if err != nil {
 		return
 	}
 
-	withWarn := false
+	p = &populate{
+		macroses:   make(map[string][]byte, 128),
+		lineNumber: 0,
+		node:       &Include{File: fn, Mandatory: true},
+		envRefs:    make(map[string]bool, 16),
+	}
+
+	newData, withWarn, err = p.do(data, lines, fn)
+	return
+}
 
-	var newData *bytes.Buffer
+// Preprocess -- the text of the file after the preprocessing as LoadFile gets it. The loaded config is not changed,
+// the environment variables are loaded on the first call as LoadFile does it
+func Preprocess(fileName string) ([]byte, error) {
+	info, err := PreprocessFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return info.Text, nil
+}
+
+// PreprocessFile -- the text of the file after the preprocessing with the details. The loaded config is not changed,
+// the environment variables are loaded on the first call as LoadFile does it.
+// The result is returned with the preprocessor error if the file was read
+func PreprocessFile(fileName string) (info *PreprocessInfo, err error) {
+	p, fn, newData, _, err := preprocess(fileName)
+	if p == nil {
+		return nil, err
+	}
+
+	info = &PreprocessInfo{
+		Text:     newData.Bytes(),
+		File:     fn,
+		Sources:  p.sources,
+		Lines:    p.lines,
+		Includes: p.node,
+		EnvRefs:  p.envRefs,
+	}
+
+	return
+}
+
+// Validate -- unmarshal the text into map[string]any, the error has the source file and the line of the preprocessed text
+func (info *PreprocessInfo) Validate() error {
+	var m map[string]any
+	err := toml.Unmarshal(info.Text, &m)
+	if err == nil {
+		return nil
+	}
+
+	lerr, ok := err.(*toml.LineError)
+	if !ok {
+		return err
+	}
+
+	src := info.File
+	if lerr.Line >= 1 && lerr.Line <= len(info.Sources) {
+		src = info.Sources[lerr.Line-1]
+	}
+
+	line := 0
+	if lerr.Line >= 1 && lerr.Line <= len(info.Lines) {
+		line = info.Lines[lerr.Line-1]
+	}
+
+	lines := bytes.Split(info.Text, []byte("\n"))
+	text := []byte{}
+	if lerr.Line >= 1 && lerr.Line <= len(lines) {
+		text = lines[lerr.Line-1]
+	}
+
+	// the line of the rendered text is replaced by the line in the source file
+	msg := lerr.Err.Error()
+	if lerr.StructField != "" {
+		msg = "(" + lerr.StructField + ") " + msg
+	}
+	if key := errorKey(lerr, info.Text); key != "" {
+		msg = key + ": " + msg
+	}
+
+	return fmt.Errorf("%s:%d: %s\n%04d | %s", src, line, msg, line, text)
+}
+
+// LoadFile parses the specified file into a Config object
+func LoadFile(fileName string, cfg any) (err error) {
+	populate, fn, newData, withWarn, err := preprocess(fileName)
+	if newData == nil {
+		return
+	}
 
 	defer func() {
 		if err != nil || withWarn {
@@ -259,24 +506,28 @@ func LoadFile(fileName string, cfg any) (err error) {
 		}
 	}()
 
-	populate := &populate{
-		macroses:   make(map[string][]byte, 128),
-		lineNumber: 0,
-	}
-
-	newData, withWarn, err = populate.do(data, filepath.Dir(fn))
 	if err != nil {
 		return
 	}
 
-	data = newData.Bytes()
+	data := newData.Bytes()
 	configText = string(data)
+	configDir = filepath.Dir(fn)
+
+	err = tomlConfig(cfg).Unmarshal(data, cfg)
+	if err != nil {
+		err = keyError(err, data, cfg)
+		return
+	}
 
-	err = toml.Unmarshal(data, cfg)
+	err = loadSections(data)
 	if err != nil {
 		return
 	}
 
+	keySources = collectKeySources(data, populate.sources)
+	resolveConfigPaths(reflect.ValueOf(cfg), "")
+
 	fullConfig = cfg
 
 	lookingForStdBlocks(cfg)
@@ -284,43 +535,202 @@ func LoadFile(fileName string, cfg any) (err error) {
 	return
 }
 
+// keyError -- add the key name to the error of the value decoding. It is the full toml path if the config (pointer to struct)
+// is given, otherwise the key of the line with the error
+func keyError(err error, data []byte, cfg any) error {
+	lerr, ok := err.(*toml.LineError)
+	if !ok {
+		return err
+	}
+
+	key := errorPath(data, cfg, lerr)
+	if key == "" {
+		key = errorKey(lerr, data)
+	}
+	if key == "" {
+		return err
+	}
+
+	return fmt.Errorf("%s: %w", key, err)
+}
+
+// errorKey -- the key name of the line with the error of the value decoding
+func errorKey(lerr *toml.LineError, data []byte) string {
+	if lerr.StructField == "" {
+		return ""
+	}
+
+	lines := bytes.Split(data, []byte("\n"))
+	if lerr.Line < 1 || lerr.Line > len(lines) {
+		return ""
+	}
+
+	key, _, ok := bytes.Cut(lines[lerr.Line-1], []byte("="))
+	key = bytes.TrimSpace(key)
+	if !ok || len(key) == 0 {
+		return ""
+	}
+
+	return string(key)
+}
+
 func lookingForStdBlocks(cfg any) {
-	c := reflect.ValueOf(cfg)
+	listeners = make(map[string]*Listener, 4)
+	dbs = make(map[string]*DB, 4)
+	dbRefs = make(map[string]string, 4)
+	mapBlocks = make(map[string]any, 4)
+	mapValues = make([]mapValue, 0, 4)
+	walkStdBlocks(reflect.ValueOf(cfg), "", false)
+}
 
-	if c.Kind() == reflect.Ptr {
-		c = c.Elem()
+// walkStdBlocks -- looking for the standard blocks in structs, maps and slices. The path is built from the toml names.
+// Struct values of maps are not addressable, so the blocks are found in their copies, checkMapBlocks puts them back.
+// found is true if there are Listener or DB blocks in the value
+func walkStdBlocks(v reflect.Value, path string, inMap bool) (found bool) {
+	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
 	}
 
-	if c.Kind() == reflect.Struct {
-		ft := c.Type()
-		fCnt := c.NumField()
+	switch v.Kind() {
+	case reflect.Struct:
+		switch v.Type() {
+		case commonTp:
+			SetCommon(stdBlockPtr[Common](v))
+			return
+		case listenerTp:
+			l := stdBlockPtr[Listener](v)
+			addListener(path, l)
+			if inMap {
+				mapBlocks[path] = l
+			}
+			return true
+		case dbTp:
+			db := stdBlockPtr[DB](v)
+			dbs[path] = db
+			if inMap {
+				mapBlocks[path] = db
+			}
+			return true
+		}
 
-		for i := range fCnt {
-			f := c.Field(i)
+		ft := v.Type()
+		for i := range v.NumField() {
+			t := ft.Field(i)
+			if !t.IsExported() {
+				continue
+			}
 
-			if f.Kind() == reflect.Ptr {
-				f = f.Elem()
+			name := misc.StructTagName(&t, "toml")
+			if name == "-" {
+				continue
 			}
 
-			if f.Kind() != reflect.Struct {
+			fPath := joinPath(path, name)
+
+			if t.Tag.Get("ref") == "db" {
+				addDBRefs(v.Field(i), fPath)
 				continue
 			}
 
-			t := ft.Field(i)
-			if !t.IsExported() {
+			if walkStdBlocks(v.Field(i), fPath, inMap) {
+				found = true
+			}
+		}
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return
+		}
+
+		for _, k := range v.MapKeys() {
+			e := v.MapIndex(k)
+			if e.Kind() == reflect.Struct {
+				c := reflect.New(e.Type()).Elem()
+				c.Set(e)
+				n := len(mapValues)
+				if walkStdBlocks(c, joinPath(path, k.String()), true) {
+					// before the values of the nested maps
+					mapValues = slices.Insert(mapValues, n, mapValue{m: v, k: k, v: c})
+					found = true
+				}
 				continue
 			}
+			if walkStdBlocks(e, joinPath(path, k.String()), true) {
+				found = true
+			}
+		}
 
-			switch f := f.Interface().(type) {
-			default:
-				lookingForStdBlocks(f)
-			case Common:
-				SetCommon(&f)
-			case Listener:
-				SetListener(&f)
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			if walkStdBlocks(v.Index(i), fmt.Sprintf("%s[%d]", path, i), inMap) {
+				found = true
 			}
 		}
 	}
+
+	return
+}
+
+// checkMapBlocks -- check the DB and Listener blocks found in maps and put the copies of the struct values back to the maps.
+// Returns the checked blocks held by pointers, they are not checked again by Check
+func checkMapBlocks(cfg any) (checked map[any]bool, err error) {
+	msgs := misc.NewMessages()
+	defer msgs.Free()
+
+	checked = make(map[any]bool, len(mapBlocks))
+
+	for _, path := range slices.Sorted(maps.Keys(mapBlocks)) {
+		block := mapBlocks[path]
+		checked[block] = true
+
+		switch block := block.(type) {
+		case *DB:
+			err = block.Check(cfg)
+		case *Listener:
+			err = block.Check(cfg)
+		}
+		if err != nil {
+			msgs.Add("%s: %s", path, err)
+		}
+	}
+
+	for _, x := range mapValues {
+		x.m.SetMapIndex(x.k, x.v)
+	}
+
+	return checked, msgs.Error()
+}
+
+// loadMapValues -- update the copies of the struct values of maps after the Check methods of the application,
+// the pointers to the blocks in the copies stay valid
+func loadMapValues() {
+	for _, x := range mapValues {
+		e := x.m.MapIndex(x.k)
+		if e.IsValid() {
+			x.v.Set(e)
+		}
+	}
+}
+
+// stdBlockPtr -- pointer to the block. The config passed by value is not addressable, so a copy is used for it
+func stdBlockPtr[T any](v reflect.Value) *T {
+	if v.CanAddr() {
+		return v.Addr().Interface().(*T)
+	}
+
+	p := new(T)
+	*p = v.Interface().(T)
+	return p
+}
+
+func joinPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
 }
 
 //----------------------------------------------------------------------------------------------------------------------------//
@@ -349,17 +759,152 @@ func SetListener(cc *Listener) {
 	listenerConfig = cc
 }
 
-// GetCommon --
+// GetListener -- the last found listener
 func GetListener() *Listener {
 	return listenerConfig
 }
 
+func addListener(path string, l *Listener) {
+	listeners[path] = l
+	SetListener(l)
+}
+
+// GetListeners -- all found listeners by their toml path (for example "http.listener" or "listeners.admin")
+func GetListeners() map[string]*Listener {
+	return listeners
+}
+
+// GetListenerByName -- listener by the full toml path or by the last path element if it is unique
+func GetListenerByName(name string) *Listener {
+	_, l := findBlock(listeners, name)
+	return l
+}
+
+// findBlock -- block by the full toml path or by the last path element if it is unique
+func findBlock[T any](list map[string]*T, name string) (path string, block *T) {
+	block, exists := list[name]
+	if exists {
+		return name, block
+	}
+
+	for p, b := range list {
+		if strings.HasSuffix(p, "."+name) {
+			if block != nil {
+				// ambiguous
+				return "", nil
+			}
+			path, block = p, b
+		}
+	}
+
+	return
+}
+
+// checkListeners -- looking for the duplicated bind addresses
+func checkListeners() error {
+	msgs := misc.NewMessages()
+	defer msgs.Free()
+
+	names := slices.Sorted(maps.Keys(listeners))
+	used := make(map[string]string, len(names))
+
+	for _, name := range names {
+		addr, _ := normalizeBindAddr(listeners[name].bindAddr())
+		prev, exists := used[addr]
+		if exists {
+			msgs.Add(`listener "%s": bind-addr "%s" is already used by listener "%s"`, name, addr, prev)
+			continue
+		}
+		used[addr] = name
+	}
+
+	return msgs.Error()
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// GetDBs -- all found DB blocks by their toml path (for example "db.primary")
+func GetDBs() map[string]*DB {
+	return dbs
+}
+
+// GetDB -- DB block by the full toml path or by the last path element if it is unique.
+// Empty name means the default connection: "default" or the only one
+func GetDB(name string) *DB {
+	_, db := findDB(name)
+	return db
+}
+
+func findDB(name string) (path string, db *DB) {
+	if name == "" {
+		path, db = findBlock(dbs, DefaultDBName)
+		if db != nil || len(dbs) != 1 {
+			return
+		}
+
+		for p, d := range dbs {
+			return p, d
+		}
+	}
+
+	return findBlock(dbs, name)
+}
+
+// addDBRefs -- string or []string field with the `ref:"db"` tag
+func addDBRefs(v reflect.Value, path string) {
+	switch v.Kind() {
+	case reflect.String:
+		dbRefs[path] = v.String()
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			if v.Index(i).Kind() == reflect.String {
+				dbRefs[fmt.Sprintf("%s[%d]", path, i)] = v.Index(i).String()
+			}
+		}
+	}
+}
+
+// checkDBs -- looking for the duplicated DSNs and references to unknown DB blocks
+func checkDBs() error {
+	msgs := misc.NewMessages()
+	defer msgs.Free()
+
+	names := slices.Sorted(maps.Keys(dbs))
+	used := make(map[string]string, len(names))
+
+	for _, name := range names {
+		dsn := dbs[name].BuildDSN()
+		if dsn == "" {
+			continue
+		}
+
+		prev, exists := used[dsn]
+		if exists {
+			msgs.Add(`db "%s": the same DSN as in "%s"`, name, prev)
+			continue
+		}
+		used[dsn] = name
+	}
+
+	for _, path := range slices.Sorted(maps.Keys(dbRefs)) {
+		_, db := findDB(dbRefs[path])
+		if db == nil {
+			msgs.Add(`%s: unknown db "%s"`, path, dbRefs[path])
+		}
+	}
+
+	return msgs.Error()
+}
+
 // ----------------------------------------------------------------------------------------------------------------------------//
 var (
 	stdReplaces = map[string]string{
-		`(password\s*=\s*")(.*)(")`: `$1*$3`,
-		`(secret\s*=\s*")(.*)(")`:   `$1*$3`,
-		`(users\s*=\s*{)(.*)(})`:    `$1*$3`,
+		`(password\s*=\s*")(.*)(")`:                           `$1*$3`,
+		`(secret\s*=\s*")(.*)(")`:                             `$1*$3`,
+		`(users\s*=\s*{)(.*)(})`:                              `$1*$3`,
+		`(dsn\s*=\s*"[a-z0-9+.-]+://[^":/@]*:)(.*)(@[^@"]*")`: `$1*$3`,
+		`(dsn\s*=\s*"[^":/@]*:)((?:[^/]|/[^/]).*)(@[^@"]*")`:  `$1*$3`,
+		`(dsn\s*=\s*"[^"]*password\s*=\s*)([^\s"]+)([\s"])`:   `$1*$3`,
 	}
 
 	replace = misc.NewReplace()
@@ -385,7 +930,12 @@ func GetText() string {
 
 // GetSecuredText -- get prepared configuration text with securing
 func GetSecuredText() string {
-	return replace.Do(configText)
+	return SecureText(configText)
+}
+
+// SecureText -- mask the secrets in the text by the same rules as GetSecuredText
+func SecureText(text string) string {
+	return replace.Do(text)
 }
 
 //----------------------------------------------------------------------------------------------------------------------------//
@@ -0,0 +1,184 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alrusov/log"
+	"github.com/alrusov/misc"
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+type (
+	// LogOutput -- [[common.log-output]]
+	LogOutput struct {
+		Type   string `toml:"type"`   // file, stderr, syslog
+		Level  string `toml:"level"`  // common.log-level by default
+		Format string `toml:"format"` // text (default), json
+
+		// file
+		Path       string   `toml:"path"`        // relative to the executable file directory
+		Rotate     string   `toml:"rotate"`      // none (default), hourly, daily
+		MaxSize    int64    `toml:"max-size"`    // bytes, 0 - unlimited
+		MaxAge     Duration `toml:"max-age"`     // 0 - keep forever
+		MaxBackups int      `toml:"max-backups"` // 0 - keep all
+		Compress   bool     `toml:"compress"`
+
+		// syslog
+		Address  string `toml:"address"`  // local socket, /dev/log by default
+		Facility string `toml:"facility"` // user (default), daemon, local0..local7
+		Tag      string `toml:"tag"`      // common.name by default
+	}
+)
+
+const (
+	// LogOutputFile --
+	LogOutputFile = "file"
+	// LogOutputStderr --
+	LogOutputStderr = "stderr"
+	// LogOutputSyslog --
+	LogOutputSyslog = "syslog"
+
+	// LogFormatText --
+	LogFormatText = "text"
+	// LogFormatJSON -- JSON lines
+	LogFormatJSON = "json"
+
+	// LogRotateNone --
+	LogRotateNone = "none"
+	// LogRotateHourly --
+	LogRotateHourly = "hourly"
+	// LogRotateDaily --
+	LogRotateDaily = "daily"
+
+	// SyslogDefaultAddress --
+	SyslogDefaultAddress = "/dev/log"
+)
+
+var (
+	knownSyslogFacilities = misc.BoolMap{
+		"kern": true, "user": true, "mail": true, "daemon": true, "auth": true, "syslog": true, "lpr": true, "news": true,
+		"uucp": true, "cron": true, "authpriv": true, "ftp": true,
+		"local0": true, "local1": true, "local2": true, "local3": true, "local4": true, "local5": true, "local6": true, "local7": true,
+	}
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// check -- validate and normalize the output, defaults are taken from the common block
+func (x *LogOutput) check(c *Common, name string, msgs *misc.Messages) {
+	var err error
+
+	x.Type = strings.ToLower(strings.TrimSpace(x.Type))
+
+	x.Level = strings.TrimSpace(x.Level)
+	if x.Level == "" {
+		x.Level = c.LogLevel
+	}
+	if x.Level != "" {
+		_, ok := log.Str2Level(x.Level)
+		if !ok {
+			msgs.Add(`%s.level: unknown level "%s"`, name, x.Level)
+		}
+	}
+
+	x.Format = strings.ToLower(strings.TrimSpace(x.Format))
+	switch x.Format {
+	case "":
+		x.Format = LogFormatText
+	case LogFormatText, LogFormatJSON:
+	default:
+		msgs.Add(`%s.format: unknown format "%s"`, name, x.Format)
+	}
+
+	switch x.Type {
+	case LogOutputFile:
+		x.Path = strings.TrimSpace(x.Path)
+		if x.Path == "" {
+			msgs.Add(`%s.path: not defined`, name)
+		} else {
+			x.Path, err = misc.AbsPath(x.Path)
+			if err != nil {
+				msgs.Add(`%s.path: %s`, name, err)
+			}
+		}
+
+		x.Rotate = strings.ToLower(strings.TrimSpace(x.Rotate))
+		switch x.Rotate {
+		case "":
+			x.Rotate = LogRotateNone
+		case LogRotateNone, LogRotateHourly, LogRotateDaily:
+		default:
+			msgs.Add(`%s.rotate: unknown value "%s"`, name, x.Rotate)
+		}
+
+		if x.MaxSize < 0 {
+			msgs.Add(`%s.max-size: negative value %d`, name, x.MaxSize)
+		}
+
+		if x.MaxAge < 0 {
+			msgs.Add(`%s.max-age: negative value`, name)
+		}
+
+		if x.MaxBackups < 0 {
+			msgs.Add(`%s.max-backups: negative value %d`, name, x.MaxBackups)
+		}
+
+	case LogOutputStderr:
+		if x.Path != "" || x.Address != "" {
+			msgs.Add(`%s: path and address are not used for stderr`, name)
+		}
+
+	case LogOutputSyslog:
+		x.Address = strings.TrimSpace(x.Address)
+		if x.Address == "" {
+			x.Address = SyslogDefaultAddress
+		}
+		if !strings.HasPrefix(x.Address, "/") {
+			msgs.Add(`%s.address: "%s" is not a local socket path`, name, x.Address)
+		}
+
+		x.Facility = strings.ToLower(strings.TrimSpace(x.Facility))
+		if x.Facility == "" {
+			x.Facility = "user"
+		}
+		if !knownSyslogFacilities[x.Facility] {
+			msgs.Add(`%s.facility: unknown facility "%s"`, name, x.Facility)
+		}
+
+		x.Tag = strings.TrimSpace(x.Tag)
+		if x.Tag == "" {
+			x.Tag = c.Name
+		}
+
+	case "":
+		msgs.Add(`%s.type: not defined`, name)
+
+	default:
+		msgs.Add(`%s.type: unknown type "%s" (valid: %s, %s, %s)`, name, x.Type, LogOutputFile, LogOutputStderr, LogOutputSyslog)
+	}
+}
+
+// checkLogOutputs --
+func (x *Common) checkLogOutputs(msgs *misc.Messages) {
+	files := make(map[string]int, len(x.LogOutputs))
+
+	for i := range x.LogOutputs {
+		o := &x.LogOutputs[i]
+		name := fmt.Sprintf("common.log-output[%d]", i)
+
+		o.check(x, name, msgs)
+
+		if o.Type == LogOutputFile && o.Path != "" {
+			prev, exists := files[o.Path]
+			if exists {
+				msgs.Add(`%s.path: "%s" is already used in common.log-output[%d]`, name, o.Path, prev)
+				continue
+			}
+			files[o.Path] = i
+		}
+	}
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
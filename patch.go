@@ -0,0 +1,721 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/naoina/toml"
+
+	"github.com/alrusov/log"
+	"github.com/alrusov/misc"
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+type (
+	// AuditRecord -- runtime change made by Set or ApplyPatch. Secrets are masked by the GetSecuredText rules
+	AuditRecord struct {
+		Time time.Time `json:"time"`
+		Op   string    `json:"op"` // "set", "patch" or "delete"
+		Path string    `json:"path"`
+		Old  string    `json:"old"`
+		New  string    `json:"new"`
+	}
+
+	patchOp struct {
+		op     string
+		path   string
+		segs   []pathSegment
+		value  any
+		text   string
+		isText bool // the value is given by Set as a text
+	}
+)
+
+var (
+	runtimeMutex = new(sync.Mutex)
+	auditLog     = []AuditRecord{}
+	runtimeCheck atomic.Bool // the blocks are checked by Set or ApplyPatch, probe-bind is skipped
+
+	textMarshalerTp = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// Set -- change the value by the path of toml names (see Lookup) after LoadFile.
+// The value is converted as the TOML decoder does it: `10`, `true`, `"text"`, `[1, 2]`, `{a = 1}`;
+// strings and types with UnmarshalText may be given without quotes: `10s`, `512KiB`.
+// Check of the nearest enclosing block is called on a copy, the config is changed only if it succeeded
+func Set(path string, value string) error {
+	segs, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+
+	if len(segs) == 0 {
+		return fmt.Errorf("empty path")
+	}
+
+	return applyOps([]*patchOp{
+		{
+			op:     "set",
+			path:   path,
+			segs:   segs,
+			text:   value,
+			isText: true,
+		},
+	})
+}
+
+// ApplyPatch -- change the config by the JSON merge patch (RFC 7386) with toml names as the keys.
+// null deletes the map element or resets the struct field to the zero value.
+// All changes are checked together and are applied only if all of them are correct
+func ApplyPatch(jsonMergePatch []byte) error {
+	var patch any
+	err := json.Unmarshal(jsonMergePatch, &patch)
+	if err != nil {
+		return fmt.Errorf("bad patch: %s", err)
+	}
+
+	m, ok := patch.(map[string]any)
+	if !ok {
+		return fmt.Errorf("bad patch: object expected")
+	}
+
+	runtimeMutex.Lock()
+	cfg := fullConfig
+	runtimeMutex.Unlock()
+
+	ops := []*patchOp{}
+	patchOps(&ops, reflect.ValueOf(cfg), m, nil, "")
+
+	if len(ops) == 0 {
+		return nil
+	}
+
+	return applyOps(ops)
+}
+
+// AuditLog -- copy of the list of runtime changes made after LoadFile
+func AuditLog() []AuditRecord {
+	runtimeMutex.Lock()
+	defer runtimeMutex.Unlock()
+
+	list := make([]AuditRecord, len(auditLog))
+	copy(list, auditLog)
+	return list
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// patchOps -- flatten the patch to the operations. Objects are merged into structs and maps of concrete types,
+// other values replace the target entirely
+func patchOps(ops *[]*patchOp, v reflect.Value, patch map[string]any, segs []pathSegment, path string) {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			v = reflect.Value{}
+			break
+		}
+		v = v.Elem()
+	}
+
+	keys := make([]string, 0, len(patch))
+	for k := range patch {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		pv := patch[k]
+		kSegs := append(append([]pathSegment{}, segs...), pathSegment{key: k})
+		kPath := joinPath(path, patchKeyName(k))
+
+		if pv == nil {
+			*ops = append(*ops, &patchOp{op: "delete", path: kPath, segs: kSegs})
+			continue
+		}
+
+		if obj, ok := pv.(map[string]any); ok && v.IsValid() {
+			child := reflect.Value{}
+
+			switch v.Kind() {
+			case reflect.Struct:
+				child, _ = structFieldByKey(v, k)
+			case reflect.Map:
+				if v.Type().Key().Kind() == reflect.String && v.Type().Elem().Kind() != reflect.Interface {
+					child = v.MapIndex(reflect.ValueOf(k).Convert(v.Type().Key()))
+				}
+			}
+
+			if child.IsValid() && isMergeable(child.Type()) {
+				patchOps(ops, child, obj, kSegs, kPath)
+				continue
+			}
+		}
+
+		*ops = append(*ops, &patchOp{op: "patch", path: kPath, segs: kSegs, value: pv})
+	}
+}
+
+// isMergeable -- the patch object is merged into the value of this type, not replaces it
+func isMergeable(tp reflect.Type) bool {
+	for tp.Kind() == reflect.Pointer {
+		tp = tp.Elem()
+	}
+
+	if reflect.PointerTo(tp).Implements(textUnmarshalerTp) {
+		return false
+	}
+
+	switch tp.Kind() {
+	case reflect.Struct:
+		return true
+	case reflect.Map:
+		return tp.Key().Kind() == reflect.String && tp.Elem().Kind() != reflect.Interface
+	}
+
+	return false
+}
+
+func patchKeyName(k string) string {
+	if strings.ContainsAny(k, ".[]\" ") {
+		return strconv.Quote(k)
+	}
+	return k
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// applyOps -- apply the operations to a copy of the config, check the changed blocks and commit.
+// The config is changed and the audit records are written only if all the operations and checks succeeded
+func applyOps(ops []*patchOp) error {
+	runtimeMutex.Lock()
+	defer runtimeMutex.Unlock()
+
+	if fullConfig == nil {
+		return fmt.Errorf("config is not loaded")
+	}
+
+	root := reflect.ValueOf(fullConfig)
+	if root.Kind() != reflect.Pointer || root.IsNil() {
+		return fmt.Errorf("config is not a pointer")
+	}
+
+	msgs := misc.NewMessages()
+	defer msgs.Free()
+
+	scratch := deepCopy(root)
+
+	for _, op := range ops {
+		err := op.setByPath(scratch)
+		if err != nil {
+			msgs.AddError(err)
+		}
+	}
+
+	if err := msgs.Error(); err != nil {
+		return err
+	}
+
+	// the nearest blocks with Check
+	type block struct {
+		segs    []pathSegment
+		path    string
+		checked reflect.Value
+	}
+
+	blocks := map[string]*block{}
+	opBlocks := make([]*block, len(ops))
+
+	for i, op := range ops {
+		n := checkedBlockDepth(root, op.segs)
+		if n < 0 {
+			continue
+		}
+
+		bPath := segsPath(op.segs[:n])
+		b, exists := blocks[bPath]
+		if !exists {
+			b = &block{segs: op.segs[:n], path: bPath}
+			blocks[bPath] = b
+		}
+		opBlocks[i] = b
+	}
+
+	bPaths := make([]string, 0, len(blocks))
+	for p := range blocks {
+		bPaths = append(bPaths, p)
+	}
+	sort.Strings(bPaths)
+
+	for _, p := range bPaths {
+		b := blocks[p]
+
+		v, err := lookupSegs(scratch, b.segs)
+		if err != nil {
+			msgs.AddError(err)
+			continue
+		}
+
+		for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+
+		// the block may be a map element, it is not addressable
+		c := reflect.New(v.Type())
+		c.Elem().Set(v)
+
+		runtimeCheck.Store(true)
+		err = callCheck(c.MethodByName("Check"), scratch.Interface())
+		runtimeCheck.Store(false)
+		if err != nil {
+			if b.path == "" {
+				msgs.AddError(err)
+			} else {
+				msgs.Add("%s: %s", b.path, err)
+			}
+			continue
+		}
+
+		b.checked = c.Elem()
+	}
+
+	if err := msgs.Error(); err != nil {
+		return err
+	}
+
+	// the records are made before the commit, they have the old values
+
+	now := time.Now()
+	records := make([]AuditRecord, 0, len(ops))
+
+	for _, op := range ops {
+		oldV, _ := lookupSegs(root, op.segs)
+		newV, _ := lookupSegs(scratch, op.segs)
+
+		rec := AuditRecord{
+			Time: now,
+			Op:   op.op,
+			Path: op.path,
+			Old:  securedValue(op.segs, oldV),
+		}
+		if op.op != "delete" {
+			rec.New = securedValue(op.segs, newV)
+		}
+		records = append(records, rec)
+	}
+
+	commit := func(target reflect.Value) error {
+		for i, op := range ops {
+			if opBlocks[i] != nil {
+				continue
+			}
+			// there is no Check, apply the operation itself
+			err := op.setByPath(target)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, p := range bPaths {
+			b := blocks[p]
+			err := setByPath(target, b.segs, "", false, func(v reflect.Value, _ string) error {
+				if v.Kind() == reflect.Pointer && !v.IsNil() {
+					// the pointer identity is preserved
+					v.Elem().Set(deepCopy(b.checked))
+					return nil
+				}
+				if v.Kind() == reflect.Pointer {
+					c := reflect.New(b.checked.Type())
+					c.Elem().Set(deepCopy(b.checked))
+					v.Set(c)
+					return nil
+				}
+				v.Set(deepCopy(b.checked))
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	// the same commit on a copy first, the config is not changed partially if it fails
+	err := commit(deepCopy(root))
+	if err != nil {
+		return err
+	}
+
+	err = commit(root)
+	if err != nil {
+		return err
+	}
+
+	lookingForStdBlocks(fullConfig)
+
+	for _, rec := range records {
+		auditLog = append(auditLog, rec)
+		log.Message(log.INFO, `Config changed at runtime: %s "%s": "%s" -> "%s"`, rec.Op, rec.Path, rec.Old, rec.New)
+	}
+
+	return nil
+}
+
+func (op *patchOp) setByPath(root reflect.Value) error {
+	return setByPath(root, op.segs, "", op.op == "delete", op.apply)
+}
+
+// apply -- change the value at the end of the path
+func (op *patchOp) apply(v reflect.Value, path string) error {
+	src := op.value
+	if op.isText {
+		src = textValue(v.Type(), op.text)
+	}
+
+	fresh := reflect.New(v.Type()).Elem()
+	fresh.Set(v)
+
+	if fresh.Kind() == reflect.Map || fresh.Kind() == reflect.Slice {
+		// replaced entirely
+		fresh.Set(reflect.Zero(fresh.Type()))
+	}
+
+	err := decodeExtra(fresh, src, DecodePath(path))
+	if err != nil {
+		return err
+	}
+
+	v.Set(fresh)
+	return nil
+}
+
+// textValue -- the value of the Set text as the TOML decoder gives it
+func textValue(tp reflect.Type, text string) any {
+	var m map[string]any
+	err := toml.Unmarshal([]byte("v = "+text), &m)
+	if err == nil {
+		if s, ok := m["v"].(string); ok {
+			return s
+		}
+	}
+
+	for tp.Kind() == reflect.Pointer {
+		tp = tp.Elem()
+	}
+
+	if tp.Kind() == reflect.String || reflect.PointerTo(tp).Implements(textUnmarshalerTp) || err != nil {
+		return strings.TrimSpace(text)
+	}
+
+	return m["v"]
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// setByPath -- call fn for the settable value by the path. Missing map elements and nil pointers are created,
+// map elements are copied and set back. The delete operation is applied to the container
+func setByPath(v reflect.Value, segs []pathSegment, done string, del bool, fn func(v reflect.Value, path string) error) error {
+	if len(segs) == 0 {
+		return fn(v, done)
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			if !v.CanSet() {
+				return fmt.Errorf("%s: is nil", pathName(done))
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setByPath(v.Elem(), segs, done, del, fn)
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return fmt.Errorf("%s: is nil", pathName(done))
+		}
+		// the interface content is not settable
+		e := reflect.New(v.Elem().Type()).Elem()
+		e.Set(v.Elem())
+		err := setByPath(e, segs, done, del, fn)
+		if err != nil {
+			return err
+		}
+		v.Set(e)
+		return nil
+	}
+
+	seg := segs[0]
+
+	if seg.isIndex {
+		done = fmt.Sprintf("%s[%d]", done, seg.index)
+
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return fmt.Errorf("%s: %s is not an array", done, v.Type())
+		}
+
+		if seg.index >= v.Len() {
+			return fmt.Errorf("%s: index %d is out of range, length is %d", done, seg.index, v.Len())
+		}
+
+		if len(segs) == 1 && del {
+			return fmt.Errorf("%s: array elements can not be deleted", done)
+		}
+
+		return setByPath(v.Index(seg.index), segs[1:], done, del, fn)
+	}
+
+	done = joinPath(done, patchKeyName(seg.key))
+
+	switch v.Kind() {
+	case reflect.Struct:
+		f, found := structFieldByKey(v, seg.key)
+		if !found || !f.CanSet() {
+			return fmt.Errorf(`%s: %s has no field with the key "%s"`, done, v.Type(), seg.key)
+		}
+
+		if len(segs) == 1 && del {
+			f.Set(reflect.Zero(f.Type()))
+			return nil
+		}
+
+		return setByPath(f, segs[1:], done, del, fn)
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("%s: %s keys are not strings", done, v.Type())
+		}
+
+		k := reflect.ValueOf(seg.key).Convert(v.Type().Key())
+
+		if len(segs) == 1 && del {
+			if !v.IsNil() {
+				v.SetMapIndex(k, reflect.Value{})
+			}
+			return nil
+		}
+
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+
+		e := reflect.New(v.Type().Elem()).Elem()
+		if old := v.MapIndex(k); old.IsValid() {
+			e.Set(old)
+		} else if len(segs) > 1 {
+			return fmt.Errorf(`%s: key "%s" not found`, done, seg.key)
+		}
+
+		err := setByPath(e, segs[1:], done, del, fn)
+		if err != nil {
+			return err
+		}
+
+		v.SetMapIndex(k, e)
+		return nil
+
+	default:
+		return fmt.Errorf("%s: %s has no keys", done, v.Type())
+	}
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// segsPath -- the path of toml names by the parsed path
+func segsPath(segs []pathSegment) (path string) {
+	for _, seg := range segs {
+		if seg.isIndex {
+			path = fmt.Sprintf("%s[%d]", path, seg.index)
+			continue
+		}
+		path = joinPath(path, patchKeyName(seg.key))
+	}
+	return
+}
+
+// checkedBlockDepth -- number of the path segments of the nearest enclosing struct with the Check(cfg any) error method,
+// -1 if there is no such struct
+func checkedBlockDepth(v reflect.Value, segs []pathSegment) int {
+	best := -1
+
+	for i := 0; ; i++ {
+		for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return best
+			}
+			v = v.Elem()
+		}
+
+		if v.Kind() == reflect.Struct {
+			if isCheckMethod(reflect.New(v.Type()).MethodByName("Check")) {
+				best = i
+			}
+		}
+
+		if i >= len(segs)-1 {
+			// the last segment may be a new or a deleted key, its own Check is not used
+			return best
+		}
+
+		next, err := lookupSegs(v, segs[i:i+1])
+		if err != nil {
+			return best
+		}
+		v = next
+	}
+}
+
+// deepCopy -- copy of the value with all exported pointers, maps, slices and interfaces duplicated
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		n := reflect.New(v.Type().Elem())
+		n.Elem().Set(deepCopy(v.Elem()))
+		return n
+
+	case reflect.Interface:
+		n := reflect.New(v.Type()).Elem()
+		if !v.IsNil() {
+			n.Set(deepCopy(v.Elem()))
+		}
+		return n
+
+	case reflect.Map:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		n := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			n.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return n
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		n := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			n.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return n
+
+	case reflect.Array:
+		n := reflect.New(v.Type()).Elem()
+		for i := range v.Len() {
+			n.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return n
+
+	case reflect.Struct:
+		// unexported fields are copied as is
+		n := reflect.New(v.Type()).Elem()
+		n.Set(v)
+		for i := range v.NumField() {
+			if n.Field(i).CanSet() {
+				n.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return n
+
+	default:
+		return v
+	}
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// valueText -- text representation of the value: UnmarshalText result, string or JSON
+func valueText(v reflect.Value) (text string, isString bool) {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return "", false
+		}
+		v = v.Elem()
+	}
+
+	if !v.IsValid() {
+		return "", false
+	}
+
+	if v.Type().Implements(textMarshalerTp) {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err == nil {
+			return string(b), true
+		}
+	}
+
+	if v.Kind() == reflect.String {
+		return v.String(), true
+	}
+
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return fmt.Sprintf("%v", v.Interface()), false
+	}
+
+	return string(b), false
+}
+
+// securedValue -- text of the value masked by the GetSecuredText rules. The rules are applied to the "key = value" line
+// for the last key, if one of the parent keys is masked (users, password) the whole value is masked
+func securedValue(segs []pathSegment, v reflect.Value) string {
+	text, isString := valueText(v)
+	if text == "" {
+		return ""
+	}
+
+	for i := len(segs) - 1; i >= 0; i-- {
+		seg := segs[i]
+		if seg.isIndex {
+			continue
+		}
+
+		prefix := seg.key + " = "
+
+		if i == len(segs)-1 {
+			value := text
+			if isString {
+				value = strconv.Quote(text)
+			}
+
+			line := prefix + value
+			masked := replace.Do(line)
+			if masked == line {
+				continue
+			}
+
+			value = strings.TrimPrefix(masked, prefix)
+			if isString {
+				if s, err := strconv.Unquote(value); err == nil {
+					value = s
+				}
+			}
+			return value
+		}
+
+		for _, probe := range []string{`"x"`, `{x}`} {
+			line := prefix + probe
+			if replace.Do(line) != line {
+				return "*"
+			}
+		}
+	}
+
+	return text
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
@@ -0,0 +1,283 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/alrusov/misc"
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+type (
+	// pathOptions -- parsed path tag, for example path:"file,readable,mode<=0600"
+	//
+	//	config-relative  resolve against the directory of the config file (see resolveConfigPaths)
+	//	exists           file or directory must exist
+	//	file             must be a regular file
+	//	dir              must be a directory
+	//	readable         must be readable
+	//	writable         must be writable
+	//	create           create the directory (for dir) or the parent directory (for file) if it does not exist
+	//	mode<=0600       permissions must not exceed the mask, checked only if the file exists
+	//	mode=0640        exact permissions, checked only if the file exists
+	pathOptions struct {
+		exists   bool
+		file     bool
+		dir      bool
+		readable bool
+		writable bool
+		create   bool
+		maxMode  *os.FileMode
+		mode     *os.FileMode
+	}
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+func parsePathTag(tag string) (opts *pathOptions, err error) {
+	opts = &pathOptions{}
+
+	for _, s := range strings.Split(tag, ",") {
+		s = strings.TrimSpace(s)
+
+		switch s {
+		case "", "config-relative":
+		case "exists":
+			opts.exists = true
+		case "file":
+			opts.file = true
+		case "dir":
+			opts.dir = true
+		case "readable":
+			opts.readable = true
+		case "writable":
+			opts.writable = true
+		case "create":
+			opts.create = true
+
+		default:
+			var mode *os.FileMode
+			var v string
+
+			switch {
+			case strings.HasPrefix(s, "mode<="):
+				v = s[len("mode<="):]
+				mode = new(os.FileMode)
+				opts.maxMode = mode
+			case strings.HasPrefix(s, "mode="):
+				v = s[len("mode="):]
+				mode = new(os.FileMode)
+				opts.mode = mode
+			default:
+				return nil, fmt.Errorf(`unknown path option "%s"`, s)
+			}
+
+			n, e := strconv.ParseUint(v, 8, 32)
+			if e != nil || n > 0o7777 {
+				return nil, fmt.Errorf(`bad mode in the path option "%s"`, s)
+			}
+			*mode = os.FileMode(n)
+		}
+	}
+
+	if opts.file && opts.dir {
+		return nil, fmt.Errorf(`path options "file" and "dir" are mutually exclusive`)
+	}
+
+	return
+}
+
+// isEmpty -- nothing to check
+func (opts *pathOptions) isEmpty() bool {
+	return !opts.exists && !opts.file && !opts.dir && !opts.readable && !opts.writable && !opts.create && opts.maxMode == nil && opts.mode == nil
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// CheckPaths -- check the fields with the path tag, all problems are returned in one error. Empty values are not checked
+func CheckPaths(cfg any) error {
+	msgs := misc.NewMessages()
+	defer msgs.Free()
+
+	checkPaths(msgs, reflect.ValueOf(cfg), "")
+
+	return msgs.Error()
+}
+
+func checkPaths(msgs *misc.Messages, v reflect.Value, path string) {
+	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		ft := v.Type()
+		for i := range v.NumField() {
+			t := ft.Field(i)
+			if !t.IsExported() {
+				continue
+			}
+
+			name := misc.StructTagName(&t, "toml")
+			if name == "-" {
+				continue
+			}
+
+			fPath := joinPath(path, name)
+
+			tag, exists := t.Tag.Lookup("path")
+			if !exists {
+				checkPaths(msgs, v.Field(i), fPath)
+				continue
+			}
+
+			opts, err := parsePathTag(tag)
+			if err != nil {
+				msgs.Add("%s: %s", fPath, err)
+				continue
+			}
+
+			if !opts.isEmpty() {
+				checkPathValue(msgs, v.Field(i), fPath, opts)
+			}
+		}
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return
+		}
+
+		for _, k := range v.MapKeys() {
+			checkPaths(msgs, v.MapIndex(k), joinPath(path, k.String()))
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			checkPaths(msgs, v.Index(i), fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+func checkPathValue(msgs *misc.Messages, v reflect.Value, path string, opts *pathOptions) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			checkPathValue(msgs, v.Elem(), path, opts)
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			checkPathValue(msgs, v.Index(i), fmt.Sprintf("%s[%d]", path, i), opts)
+		}
+
+	case reflect.String:
+		if v.String() != "" {
+			checkPath(msgs, path, v.String(), opts)
+		}
+
+	default:
+		msgs.Add("%s: path tag is applicable to the strings only", path)
+	}
+}
+
+// checkPath -- check one path according to the options
+func checkPath(msgs *misc.Messages, name string, path string, opts *pathOptions) {
+	if opts.create {
+		dir := path
+		if !opts.dir {
+			dir = filepath.Dir(path)
+		}
+
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			msgs.Add("%s: %s", name, err)
+			return
+		}
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) && !opts.exists && !opts.file && !opts.dir && !opts.readable && !opts.writable {
+			// existence is not required
+			return
+		}
+
+		msgs.Add("%s: %s", name, err)
+		return
+	}
+
+	switch {
+	case opts.file && !fi.Mode().IsRegular():
+		msgs.Add(`%s: "%s" is not a regular file`, name, path)
+		return
+	case opts.dir && !fi.IsDir():
+		msgs.Add(`%s: "%s" is not a directory`, name, path)
+		return
+	}
+
+	perm := fi.Mode().Perm()
+
+	if opts.maxMode != nil && perm&^*opts.maxMode != 0 {
+		msgs.Add(`%s: "%s" has too open permissions %04o, %04o at most is allowed`, name, path, perm, *opts.maxMode)
+	}
+
+	if opts.mode != nil && perm != *opts.mode {
+		msgs.Add(`%s: "%s" has permissions %04o, %04o is expected`, name, path, perm, *opts.mode)
+	}
+
+	if opts.readable {
+		err = checkReadable(path, fi.IsDir())
+		if err != nil {
+			msgs.Add("%s: not readable: %s", name, err)
+		}
+	}
+
+	if opts.writable {
+		err = checkWritable(path, fi.IsDir())
+		if err != nil {
+			msgs.Add("%s: not writable: %s", name, err)
+		}
+	}
+}
+
+func checkReadable(path string, isDir bool) error {
+	if isDir {
+		_, err := os.ReadDir(path)
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func checkWritable(path string, isDir bool) error {
+	if isDir {
+		f, err := os.CreateTemp(path, ".write-test-*")
+		if err != nil {
+			return err
+		}
+		f.Close()
+		return os.Remove(f.Name())
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+type (
+	// ReloadPlan -- changes between two configs split by the reload tag
+	ReloadPlan struct {
+		Live    []Change `json:"live"`    // can be applied without the restart
+		Restart []Change `json:"restart"` // need the restart of the application
+	}
+)
+
+const (
+	// ReloadLive -- reload:"live", the field can be changed without the restart
+	ReloadLive = "live"
+	// ReloadRestart -- reload:"restart", the restart is needed. It is the default for the fields without the tag
+	ReloadRestart = "restart"
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// CompareForReload -- changes between two configs classified by the reload:"live|restart" tag.
+// The tag applies to the whole value of the field (nested structs, maps, slices) unless the nested fields have their own tags.
+// Fields without the tag in all the path require the restart
+func CompareForReload(oldCfg any, newCfg any) *ReloadPlan {
+	plan := &ReloadPlan{
+		Live:    []Change{},
+		Restart: []Change{},
+	}
+
+	for _, c := range Diff(oldCfg, newCfg) {
+		if c.Reload == ReloadLive {
+			plan.Live = append(plan.Live, c)
+			continue
+		}
+		plan.Restart = append(plan.Restart, c)
+	}
+
+	return plan
+}
+
+// NeedRestart -- there are changes that can not be applied live
+func (p *ReloadPlan) NeedRestart() bool {
+	return len(p.Restart) != 0
+}
+
+// IsEmpty -- configs are the same
+func (p *ReloadPlan) IsEmpty() bool {
+	return len(p.Live) == 0 && len(p.Restart) == 0
+}
+
+// fieldReload -- the reload class of the field, inherited from the parent if the field has no tag. Unknown values mean the restart
+func fieldReload(t *reflect.StructField, parent string) string {
+	tag, exists := t.Tag.Lookup("reload")
+	if !exists {
+		return parent
+	}
+
+	if strings.TrimSpace(tag) == ReloadLive {
+		return ReloadLive
+	}
+
+	return ReloadRestart
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
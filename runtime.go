@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+type (
+	// RuntimeChange -- runtime setting changed by Common.Apply
+	RuntimeChange struct {
+		Name string `json:"name"`
+		Old  string `json:"old"`
+		New  string `json:"new"`
+	}
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// Apply -- apply go-max-procs, gc-percent and memory-limit to the runtime. Check must be called before.
+// Returns the list of the changed settings. The timezone is not applied, time.Local is not changed, use Location
+func (x *Common) Apply() (changes []RuntimeChange, err error) {
+	if x.Location == nil {
+		return nil, fmt.Errorf("common: Check was not called")
+	}
+
+	changes = make([]RuntimeChange, 0, 4)
+
+	add := func(name string, old string, new string) {
+		if old != new {
+			changes = append(changes, RuntimeChange{Name: name, Old: old, New: new})
+		}
+	}
+
+	// go-max-procs
+
+	oldProcs := runtime.GOMAXPROCS(0)
+	procs := x.GoMaxProcs
+
+	if procs <= 0 {
+		runtime.SetDefaultGOMAXPROCS()
+		procs += runtime.GOMAXPROCS(0)
+		if procs < 1 {
+			procs = 1
+		}
+	}
+
+	runtime.GOMAXPROCS(procs)
+	add("go-max-procs", strconv.Itoa(oldProcs), strconv.Itoa(procs))
+
+	// gc-percent
+
+	if x.GCPercent != 0 {
+		percent := x.GCPercent
+		if percent < 0 {
+			percent = -1
+		}
+
+		old := debug.SetGCPercent(percent)
+		add("gc-percent", gcPercentName(old), gcPercentName(percent))
+	}
+
+	// memory-limit
+
+	if x.MemoryLimit > 0 {
+		old := debug.SetMemoryLimit(x.MemoryLimit)
+		add("memory-limit", memoryLimitName(old), memoryLimitName(x.MemoryLimit))
+	}
+
+	return
+}
+
+func gcPercentName(v int) string {
+	if v < 0 {
+		return "off"
+	}
+	return strconv.Itoa(v)
+}
+
+func memoryLimitName(v int64) string {
+	if v == math.MaxInt64 {
+		return "unlimited"
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
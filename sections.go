@@ -0,0 +1,295 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/naoina/toml"
+
+	"github.com/alrusov/log"
+	"github.com/alrusov/misc"
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+var (
+	knownSectionsMutex = new(sync.RWMutex)
+	knownSections      = map[string]*section{}
+
+	strictMode = false
+)
+
+type (
+	section struct {
+		prototype any // pointer to struct
+		value     any // decoded by LoadFile, the same type as prototype
+	}
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// SetStrictMode -- report the unknown keys in the registered sections and the unknown tables under the dotted section names
+// ("modules.queue" if only "modules.cache" is registered). By default they are ignored, the tables with a warning.
+// Unknown top level keys are errors in any mode
+func SetStrictMode(strict bool) {
+	strictMode = strict
+}
+
+// RegisterSection -- the [name] table of the config file is decoded by LoadFile into a copy of the prototype (pointer to struct)
+// and checked by Check if the prototype has the "Check(cfg any) error" method. The name may be dotted: "modules.cache"
+func RegisterSection(name string, prototype any) (err error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf(`empty section name`)
+	}
+
+	if prototype == nil {
+		return fmt.Errorf(`prototype is null`)
+	}
+
+	vp := reflect.ValueOf(prototype)
+	if vp.Kind() != reflect.Ptr || vp.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf(`"%#v" is not a pointer to struct`, prototype)
+	}
+
+	knownSectionsMutex.Lock()
+	defer knownSectionsMutex.Unlock()
+
+	_, exists := knownSections[name]
+	if exists {
+		return fmt.Errorf(`section "%s" is already defined`, name)
+	}
+
+	knownSections[name] = &section{
+		prototype: prototype,
+	}
+
+	return
+}
+
+// UnregisterSection --
+func UnregisterSection(name string) (err error) {
+	knownSectionsMutex.Lock()
+	defer knownSectionsMutex.Unlock()
+
+	_, exists := knownSections[name]
+	if !exists {
+		return fmt.Errorf(`section "%s" is not defined`, name)
+	}
+
+	delete(knownSections, name)
+
+	return
+}
+
+// GetSection -- decoded section, available after LoadFile. If the section is absent in the file, the prototype values and defaults are used
+func GetSection[T any](name string) (*T, bool) {
+	knownSectionsMutex.RLock()
+	defer knownSectionsMutex.RUnlock()
+
+	s, exists := knownSections[name]
+	if !exists || s.value == nil {
+		return nil, false
+	}
+
+	v, ok := s.value.(*T)
+	return v, ok
+}
+
+// newValue -- a fresh copy of the prototype
+func (s *section) newValue() any {
+	proto := reflect.ValueOf(s.prototype)
+	v := reflect.New(proto.Elem().Type())
+	v.Elem().Set(proto.Elem())
+	return v.Interface()
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// loadSections -- decode the registered sections
+func loadSections(data []byte) error {
+	msgs := misc.NewMessages()
+	defer msgs.Free()
+
+	knownSectionsMutex.Lock()
+	defer knownSectionsMutex.Unlock()
+
+	for _, s := range knownSections {
+		s.value = nil
+	}
+
+	var raw map[string]any
+	err := toml.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(knownSections))
+	for name := range knownSections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := knownSections[name]
+		v := s.newValue()
+
+		opts := []DecodeOption{DecodePath(name)}
+		if strictMode {
+			opts = append(opts, DecodeStrict())
+		}
+
+		err = decodeExtra(reflect.ValueOf(v).Elem(), sectionData(raw, name), opts...)
+		if err != nil {
+			msgs.AddError(err)
+			continue
+		}
+
+		s.value = v
+	}
+
+	for _, name := range unknownSubsections("", raw) {
+		if strictMode {
+			msgs.Add(`unknown section "%s"`, name)
+			continue
+		}
+		log.Message(log.WARNING, `Unknown config section "%s" is ignored`, name)
+	}
+
+	return msgs.Error()
+}
+
+// tomlConfig -- the top level tables of the registered sections are not decoding errors, loadSections decodes them
+func tomlConfig(cfg any) *toml.Config {
+	var rootTp reflect.Type
+	if v := reflect.Indirect(reflect.ValueOf(cfg)); v.IsValid() {
+		rootTp = v.Type()
+	}
+
+	c := toml.DefaultConfig
+	c.MissingField = func(typ reflect.Type, key string) error {
+		if rootTp != nil && typ == rootTp && isSectionName(key) {
+			return nil
+		}
+		// the same as the decoder without MissingField
+		return fmt.Errorf("field corresponding to `%s' is not defined in %v", key, typ)
+	}
+
+	return &c
+}
+
+// isSectionName -- the key is the name or the first element of the dotted name of a registered section
+func isSectionName(key string) bool {
+	knownSectionsMutex.RLock()
+	defer knownSectionsMutex.RUnlock()
+
+	key = normKeyName(key)
+
+	for name := range knownSections {
+		first, _, _ := strings.Cut(name, ".")
+		if normKeyName(first) == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unknownSubsections -- the keys under the prefixes of the dotted section names that are not the parts of the registered names.
+// knownSectionsMutex must be locked
+func unknownSubsections(path string, v any) (unknown []string) {
+	if path != "" {
+		if _, exists := knownSections[path]; exists {
+			return nil
+		}
+	}
+
+	m, ok := v.(map[string]any)
+	if !ok {
+		return []string{path}
+	}
+
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		p := key
+		if path != "" {
+			p = path + "." + key
+		}
+
+		isPrefix := false
+		for name := range knownSections {
+			if name == p || strings.HasPrefix(name, p+".") {
+				isPrefix = true
+				break
+			}
+		}
+
+		switch {
+		case isPrefix:
+			unknown = append(unknown, unknownSubsections(p, m[key])...)
+		case path != "":
+			unknown = append(unknown, p)
+		}
+	}
+
+	return
+}
+
+// sectionData -- the value by the dotted name
+func sectionData(raw map[string]any, name string) any {
+	var v any = raw
+
+	for _, key := range strings.Split(name, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+
+	return v
+}
+
+// checkSections -- call Check of the decoded sections
+func checkSections(cfg any) error {
+	msgs := misc.NewMessages()
+	defer msgs.Free()
+
+	knownSectionsMutex.RLock()
+	defer knownSectionsMutex.RUnlock()
+
+	names := make([]string, 0, len(knownSections))
+	for name := range knownSections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := knownSections[name]
+		if s.value == nil {
+			continue
+		}
+
+		m := reflect.ValueOf(s.value).MethodByName("Check")
+		if !isCheckMethod(m) {
+			continue
+		}
+
+		err := callCheck(m, cfg)
+		if err != nil {
+			msgs.Add("%s: %s", name, err)
+		}
+	}
+
+	return msgs.Error()
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
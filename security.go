@@ -0,0 +1,361 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/alrusov/misc"
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+type (
+	// ListenerProxy -- reverse proxy settings
+	ListenerProxy struct {
+		// CIDRs or single addresses of the proxies whose headers are trusted
+		TrustedProxiesSlice []string       `toml:"trusted-proxies"`
+		TrustedProxies      []netip.Prefix `toml:"-"`
+
+		// Header with the client address chain, X-Forwarded-For by default
+		RealIPHeader string `toml:"real-ip-header"`
+	}
+
+	// ListenerCORS --
+	ListenerCORS struct {
+		// "*", "https://example.com", "https://*.example.com"
+		AllowedOrigins   []string `toml:"allowed-origins"`
+		AllowedMethods   []string `toml:"allowed-methods"`
+		AllowedHeaders   []string `toml:"allowed-headers"`
+		ExposedHeaders   []string `toml:"exposed-headers"`
+		AllowCredentials bool     `toml:"allow-credentials"`
+		MaxAge           Duration `toml:"max-age"`
+	}
+
+	// ListenerHeaders -- security headers added to every response
+	ListenerHeaders struct {
+		HSTSMaxAge            Duration       `toml:"hsts-max-age"` // 0 - do not send, HTTPS only
+		HSTSIncludeSubdomains bool           `toml:"hsts-include-subdomains"`
+		HSTSPreload           bool           `toml:"hsts-preload"`
+		ContentSecurityPolicy string         `toml:"content-security-policy"`
+		FrameOptions          string         `toml:"x-frame-options"` // DENY, SAMEORIGIN
+		ContentTypeNosniff    bool           `toml:"x-content-type-nosniff"`
+		ReferrerPolicy        string         `toml:"referrer-policy"`
+		Custom                misc.StringMap `toml:"custom"`
+	}
+)
+
+const (
+	// ProxyDefaultRealIPHeader --
+	ProxyDefaultRealIPHeader = "X-Forwarded-For"
+)
+
+var (
+	corsDefaultMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+
+	knownMethods = misc.BoolMap{
+		http.MethodGet:     true,
+		http.MethodHead:    true,
+		http.MethodPost:    true,
+		http.MethodPut:     true,
+		http.MethodPatch:   true,
+		http.MethodDelete:  true,
+		http.MethodConnect: true,
+		http.MethodOptions: true,
+		http.MethodTrace:   true,
+	}
+
+	knownFrameOptions = misc.BoolMap{
+		"DENY":       true,
+		"SAMEORIGIN": true,
+	}
+
+	knownReferrerPolicies = misc.BoolMap{
+		"no-referrer":                     true,
+		"no-referrer-when-downgrade":      true,
+		"origin":                          true,
+		"origin-when-cross-origin":        true,
+		"same-origin":                     true,
+		"strict-origin":                   true,
+		"strict-origin-when-cross-origin": true,
+		"unsafe-url":                      true,
+	}
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// Check --
+func (x *ListenerProxy) Check(cfg any) (err error) {
+	msgs := misc.NewMessages()
+	defer msgs.Free()
+
+	x.TrustedProxies = nil
+
+	for _, s := range x.TrustedProxiesSlice {
+		prefix, err := ParseCIDR(s)
+		if err != nil {
+			msgs.Add(`trusted-proxies: "%s": %s`, strings.TrimSpace(s), err)
+			continue
+		}
+
+		x.TrustedProxies = append(x.TrustedProxies, prefix)
+	}
+
+	x.RealIPHeader = strings.TrimSpace(x.RealIPHeader)
+	if x.RealIPHeader == "" {
+		if len(x.TrustedProxies) != 0 {
+			x.RealIPHeader = ProxyDefaultRealIPHeader
+		}
+	} else {
+		x.RealIPHeader = http.CanonicalHeaderKey(x.RealIPHeader)
+	}
+
+	return msgs.Error()
+}
+
+// IsTrusted --
+func (x *ListenerProxy) IsTrusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range x.TrustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// RealIP -- the client address. The header is used only if the request came from a trusted proxy,
+// addresses of the trusted proxies are skipped from the right end of the chain
+func (x *ListenerProxy) RealIP(r *http.Request) string {
+	remote := r.RemoteAddr
+	host, _, err := net.SplitHostPort(remote)
+	if err == nil {
+		remote = host
+	}
+
+	addr, err := netip.ParseAddr(remote)
+	if err != nil || x.RealIPHeader == "" || !x.IsTrusted(addr) {
+		return remote
+	}
+
+	chain := strings.Split(strings.Join(r.Header.Values(x.RealIPHeader), ","), ",")
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		s := strings.TrimSpace(chain[i])
+		if s == "" {
+			continue
+		}
+
+		a, err := netip.ParseAddr(s)
+		if err != nil {
+			// garbage in the header, the last trusted address is the best known
+			break
+		}
+
+		addr = a
+		if !x.IsTrusted(a) {
+			break
+		}
+	}
+
+	return addr.Unmap().String()
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// Check --
+func (x *ListenerCORS) Check(cfg any) (err error) {
+	msgs := misc.NewMessages()
+	defer msgs.Free()
+
+	for i, s := range x.AllowedOrigins {
+		s, err = normalizeOrigin(s)
+		if err != nil {
+			msgs.Add("allowed-origins: %s", err)
+			continue
+		}
+
+		if s == "*" && x.AllowCredentials {
+			msgs.Add(`allowed-origins: "*" cannot be used with allow-credentials`)
+		}
+
+		x.AllowedOrigins[i] = s
+	}
+
+	if len(x.AllowedOrigins) == 0 {
+		return msgs.Error()
+	}
+
+	if len(x.AllowedMethods) == 0 {
+		x.AllowedMethods = append([]string{}, corsDefaultMethods...)
+	}
+
+	for i, s := range x.AllowedMethods {
+		s = strings.ToUpper(strings.TrimSpace(s))
+		if !knownMethods[s] {
+			msgs.Add(`allowed-methods: unknown method "%s"`, s)
+			continue
+		}
+		x.AllowedMethods[i] = s
+	}
+
+	checkHeaderNames(msgs, "allowed-headers", x.AllowedHeaders)
+	checkHeaderNames(msgs, "exposed-headers", x.ExposedHeaders)
+
+	if x.MaxAge < 0 {
+		msgs.Add("max-age: negative value")
+	}
+
+	return msgs.Error()
+}
+
+// normalizeOrigin -- "*" or scheme://host[:port], the host may begin with "*."
+func normalizeOrigin(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "*" {
+		return s, nil
+	}
+
+	u, err := url.Parse(strings.TrimRight(s, "/"))
+	if err != nil {
+		return s, fmt.Errorf(`"%s": %s`, s, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return s, fmt.Errorf(`"%s": scheme must be http or https`, s)
+	}
+
+	if u.Host == "" || u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return s, fmt.Errorf(`"%s": must be scheme://host[:port]`, s)
+	}
+
+	host := strings.TrimPrefix(u.Hostname(), "*.")
+	if host == "" || strings.Contains(host, "*") {
+		return s, fmt.Errorf(`"%s": bad host`, s)
+	}
+
+	if port := u.Port(); port != "" {
+		n, err := strconv.Atoi(port)
+		if err != nil || n <= 0 || n > 65535 {
+			return s, fmt.Errorf(`"%s": bad port`, s)
+		}
+	}
+
+	return u.Scheme + "://" + u.Host, nil
+}
+
+func checkHeaderNames(msgs *misc.Messages, name string, list []string) {
+	for i, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "*" {
+			list[i] = s
+			continue
+		}
+
+		if s == "" || strings.ContainsFunc(s, func(r rune) bool {
+			return !(r == '-' || r == '_' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'))
+		}) {
+			msgs.Add(`%s: bad header name "%s"`, name, s)
+			continue
+		}
+
+		list[i] = http.CanonicalHeaderKey(s)
+	}
+}
+
+// IsOriginAllowed --
+func (x *ListenerCORS) IsOriginAllowed(origin string) bool {
+	origin = strings.ToLower(strings.TrimRight(strings.TrimSpace(origin), "/"))
+	if origin == "" {
+		return false
+	}
+
+	for _, o := range x.AllowedOrigins {
+		if o == "*" || o == origin {
+			return true
+		}
+
+		scheme, host, ok := strings.Cut(o, "://*.")
+		if ok && strings.HasPrefix(origin, scheme+"://") && strings.HasSuffix(origin, "."+host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// Check --
+func (x *ListenerHeaders) Check(cfg any) (err error) {
+	msgs := misc.NewMessages()
+	defer msgs.Free()
+
+	if x.HSTSMaxAge < 0 {
+		msgs.Add("hsts-max-age: negative value")
+	}
+
+	x.FrameOptions = strings.ToUpper(strings.TrimSpace(x.FrameOptions))
+	if x.FrameOptions != "" && !knownFrameOptions[x.FrameOptions] {
+		msgs.Add(`x-frame-options: unknown value "%s"`, x.FrameOptions)
+	}
+
+	x.ReferrerPolicy = strings.ToLower(strings.TrimSpace(x.ReferrerPolicy))
+	if x.ReferrerPolicy != "" && !knownReferrerPolicies[x.ReferrerPolicy] {
+		msgs.Add(`referrer-policy: unknown value "%s"`, x.ReferrerPolicy)
+	}
+
+	x.ContentSecurityPolicy = strings.TrimSpace(x.ContentSecurityPolicy)
+
+	if len(x.Custom) != 0 {
+		custom := make(misc.StringMap, len(x.Custom))
+		for name, v := range x.Custom {
+			list := []string{name}
+			checkHeaderNames(msgs, "custom", list)
+			custom[list[0]] = v
+		}
+		x.Custom = custom
+	}
+
+	return msgs.Error()
+}
+
+// Set -- add the security headers to the response header
+func (x *ListenerHeaders) Set(h http.Header, isTLS bool) {
+	if isTLS && x.HSTSMaxAge > 0 {
+		v := "max-age=" + strconv.FormatInt(int64(x.HSTSMaxAge.D().Seconds()), 10)
+		if x.HSTSIncludeSubdomains {
+			v += "; includeSubDomains"
+		}
+		if x.HSTSPreload {
+			v += "; preload"
+		}
+		h.Set("Strict-Transport-Security", v)
+	}
+
+	if x.ContentSecurityPolicy != "" {
+		h.Set("Content-Security-Policy", x.ContentSecurityPolicy)
+	}
+
+	if x.FrameOptions != "" {
+		h.Set("X-Frame-Options", x.FrameOptions)
+	}
+
+	if x.ContentTypeNosniff {
+		h.Set("X-Content-Type-Options", "nosniff")
+	}
+
+	if x.ReferrerPolicy != "" {
+		h.Set("Referrer-Policy", x.ReferrerPolicy)
+	}
+
+	for name, v := range x.Custom {
+		h.Set(name, v)
+	}
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"net/http"
+
+	"github.com/alrusov/misc"
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// checkServer -- HTTP server settings defaults
+func (x *Listener) checkServer(msgs *misc.Messages) {
+	if x.ReadTimeout <= 0 {
+		x.ReadTimeout = x.Timeout
+	}
+
+	switch {
+	case x.ReadHeaderTimeout <= 0:
+		x.ReadHeaderTimeout = x.ReadTimeout
+	case x.ReadTimeout > 0 && x.ReadHeaderTimeout > x.ReadTimeout:
+		msgs.Add("listener.read-header-timeout: %s is greater than read-timeout %s", x.ReadHeaderTimeout.D(), x.ReadTimeout.D())
+	}
+
+	if x.WriteTimeout <= 0 {
+		x.WriteTimeout = x.Timeout
+	}
+
+	if x.IdleTimeout <= 0 {
+		x.IdleTimeout = ListenerDefaultIdleTimeout
+	}
+
+	if x.ShutdownTimeout <= 0 {
+		x.ShutdownTimeout = ListenerDefaultShutdownTimeout
+	}
+
+	switch {
+	case x.MaxHeaderBytes == 0:
+		x.MaxHeaderBytes = ListenerDefaultMaxHeaderBytes
+	case x.MaxHeaderBytes < 0:
+		msgs.Add("listener.max-header-bytes: negative value %d", x.MaxHeaderBytes)
+	}
+
+	if x.MaxBodySize < 0 {
+		msgs.Add("listener.max-body-size: negative value %d", x.MaxBodySize)
+	}
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// HTTPServer -- *http.Server configured by the listener settings. Check must be called before.
+// For unix sockets use Listen and srv.Serve instead of srv.ListenAndServe
+func (x *Listener) HTTPServer(handler http.Handler) (srv *http.Server, err error) {
+	tlsConfig, err := x.TLSConfig()
+	if err != nil {
+		return
+	}
+
+	if x.MaxBodySize > 0 {
+		handler = http.MaxBytesHandler(handler, x.MaxBodySize)
+	}
+
+	srv = &http.Server{
+		Addr:              x.Addr,
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: x.ReadHeaderTimeout.D(),
+		ReadTimeout:       x.ReadTimeout.D(),
+		WriteTimeout:      x.WriteTimeout.D(),
+		IdleTimeout:       x.IdleTimeout.D(),
+		MaxHeaderBytes:    x.MaxHeaderBytes,
+	}
+
+	if x.DisableKeepAlive {
+		srv.SetKeepAlivesEnabled(false)
+	}
+
+	return
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
@@ -0,0 +1,356 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/naoina/toml"
+	"github.com/naoina/toml/ast"
+
+	"github.com/alrusov/misc"
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+type (
+	keySource struct {
+		file  string
+		value string // source string value, empty for other types
+	}
+)
+
+var (
+	keySources = map[string]keySource{} // toml path -> where the key was defined
+
+	pathTp = reflect.TypeOf(Path(""))
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// SourceFile -- the file where the key was defined, path is built from the toml names: "http.listener.icon-file", "list[1].name"
+func SourceFile(path string) (fileName string, exists bool) {
+	src, exists := keySources[path]
+	return src.file, exists
+}
+
+// collectKeySources -- parse the preprocessed text and bind the keys to the source files of their lines
+func collectKeySources(data []byte, lineSources []string) map[string]keySource {
+	list := make(map[string]keySource, 128)
+
+	root, err := toml.Parse(data)
+	if err != nil {
+		return list
+	}
+
+	file := func(line int) string {
+		if line < 1 || line > len(lineSources) {
+			return ""
+		}
+		return lineSources[line-1]
+	}
+
+	var walkTable func(t *ast.Table, path string)
+	var walkValue func(v ast.Value, path string, line int)
+
+	walkValue = func(v ast.Value, path string, line int) {
+		src := keySource{file: file(line)}
+
+		switch v := v.(type) {
+		case *ast.String:
+			src.value = v.Value
+		case *ast.Table:
+			walkTable(v, path)
+		case *ast.Array:
+			for i, v := range v.Value {
+				walkValue(v, fmt.Sprintf("%s[%d]", path, i), line)
+			}
+		}
+
+		list[path] = src
+	}
+
+	walkTable = func(t *ast.Table, path string) {
+		for name, f := range t.Fields {
+			fPath := joinPath(path, name)
+
+			switch f := f.(type) {
+			case *ast.KeyValue:
+				walkValue(f.Value, fPath, f.Line)
+			case *ast.Table:
+				list[fPath] = keySource{file: file(f.Line)}
+				walkTable(f, fPath)
+			case []*ast.Table:
+				for i, t := range f {
+					iPath := fmt.Sprintf("%s[%d]", fPath, i)
+					list[iPath] = keySource{file: file(t.Line)}
+					walkTable(t, iPath)
+				}
+			}
+		}
+	}
+
+	walkTable(root, "")
+
+	return list
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// errorPath -- toml path of the struct field of the decoding error ("http.listener.timeout" for the error in the inline table
+// of the "listener" line), the empty string if it is not found
+func errorPath(data []byte, cfg any, lerr *toml.LineError) string {
+	if cfg == nil || lerr.StructField == "" {
+		return ""
+	}
+
+	root, err := toml.Parse(data)
+	if err != nil {
+		return ""
+	}
+
+	return errorPathInTable(root, reflect.TypeOf(cfg), lerr, "")
+}
+
+func errorPathInTable(t *ast.Table, tp reflect.Type, lerr *toml.LineError, path string) string {
+	for tp.Kind() == reflect.Pointer {
+		tp = tp.Elem()
+	}
+
+	names := make([]string, 0, len(t.Fields))
+	for name := range t.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := t.Fields[name]
+		fPath := joinPath(path, patchKeyName(name))
+
+		var fTp reflect.Type
+
+		switch tp.Kind() {
+		case reflect.Struct:
+			sf, found := typeFieldByKey(tp, name)
+			if !found {
+				continue
+			}
+			if kv, ok := f.(*ast.KeyValue); ok && kv.Line == lerr.Line && tp.String()+"."+sf.Name == lerr.StructField {
+				return fPath
+			}
+			fTp = sf.Type
+		case reflect.Map:
+			fTp = tp.Elem()
+		default:
+			return ""
+		}
+
+		if p := errorPathInValue(f, fTp, lerr, fPath); p != "" {
+			return p
+		}
+	}
+
+	return ""
+}
+
+func errorPathInValue(f any, tp reflect.Type, lerr *toml.LineError, path string) string {
+	for tp.Kind() == reflect.Pointer {
+		tp = tp.Elem()
+	}
+
+	elemTp := tp
+	if tp.Kind() == reflect.Slice || tp.Kind() == reflect.Array {
+		elemTp = tp.Elem()
+	}
+
+	switch f := f.(type) {
+	case *ast.KeyValue:
+		return errorPathInValue(f.Value, tp, lerr, path)
+	case *ast.Table:
+		return errorPathInTable(f, tp, lerr, path)
+	case []*ast.Table:
+		for i, t := range f {
+			if p := errorPathInTable(t, elemTp, lerr, fmt.Sprintf("%s[%d]", path, i)); p != "" {
+				return p
+			}
+		}
+	case *ast.Array:
+		for i, v := range f.Value {
+			if p := errorPathInValue(v, elemTp, lerr, fmt.Sprintf("%s[%d]", path, i)); p != "" {
+				return p
+			}
+		}
+	}
+
+	return ""
+}
+
+// typeFieldByKey -- the same as structFieldByKey for the type
+func typeFieldByKey(tp reflect.Type, key string) (reflect.StructField, bool) {
+	for i := range tp.NumField() {
+		t := tp.Field(i)
+
+		tag, tagged := t.Tag.Lookup("toml")
+		if t.Anonymous && !tagged && t.Type.Kind() == reflect.Struct {
+			f, found := typeFieldByKey(t.Type, key)
+			if found {
+				return f, true
+			}
+			continue
+		}
+
+		if !t.IsExported() {
+			continue
+		}
+
+		if tagged {
+			name, _, _ := strings.Cut(tag, ",")
+			if name == key && name != "-" {
+				return t, true
+			}
+			continue
+		}
+
+		if normKeyName(t.Name) == normKeyName(key) {
+			return t, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// resolveConfigPaths -- make the Path values and the strings with the path:"config-relative" tag absolute
+// relative to the directory of the file where they were defined
+func resolveConfigPaths(v reflect.Value, path string) {
+	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		ft := v.Type()
+		for i := range v.NumField() {
+			t := ft.Field(i)
+			if !t.IsExported() {
+				continue
+			}
+
+			name := misc.StructTagName(&t, "toml")
+			if name == "-" {
+				continue
+			}
+
+			fPath := joinPath(path, name)
+			f := v.Field(i)
+
+			if isConfigRelative(&t) {
+				resolveConfigPath(f, fPath)
+				continue
+			}
+
+			resolveConfigPaths(f, fPath)
+		}
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return
+		}
+
+		for _, k := range v.MapKeys() {
+			// map elements are not addressable
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(v.MapIndex(k))
+			resolveConfigPaths(e, joinPath(path, k.String()))
+			v.SetMapIndex(k, e)
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			iPath := fmt.Sprintf("%s[%d]", path, i)
+			if v.Type().Elem() == pathTp {
+				resolveConfigPath(v.Index(i), iPath)
+				continue
+			}
+			resolveConfigPaths(v.Index(i), iPath)
+		}
+	}
+}
+
+func isConfigRelative(t *reflect.StructField) bool {
+	tp := t.Type
+	for tp.Kind() == reflect.Ptr || tp.Kind() == reflect.Slice {
+		tp = tp.Elem()
+	}
+
+	if tp == pathTp {
+		return true
+	}
+
+	if tp.Kind() != reflect.String {
+		return false
+	}
+
+	for _, opt := range strings.Split(t.Tag.Get("path"), ",") {
+		if strings.TrimSpace(opt) == "config-relative" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func resolveConfigPath(v reflect.Value, path string) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			resolveConfigPath(v.Elem(), path)
+		}
+		return
+
+	case reflect.Slice:
+		for i := range v.Len() {
+			resolveConfigPath(v.Index(i), fmt.Sprintf("%s[%d]", path, i))
+		}
+		return
+
+	case reflect.String:
+	default:
+		return
+	}
+
+	src, exists := keySources[path]
+	if !exists || src.file == "" || !v.CanSet() {
+		// default value or not from the file
+		return
+	}
+
+	s, err := configRelativePath(src.value, filepath.Dir(src.file))
+	if err != nil || s == "" {
+		return
+	}
+
+	v.SetString(s)
+}
+
+// configRelativePath -- absolute path, a relative one is used relative to the base. The @, $ and ^ prefixes work as in misc.AbsPathEx
+func configRelativePath(s string, base string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+
+	if !filepath.IsAbs(s) && !strings.ContainsAny(s[0:1], "@$^") {
+		s = "^" + s
+	}
+
+	return misc.AbsPathEx(s, base)
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
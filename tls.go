@@ -0,0 +1,291 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alrusov/log"
+	"github.com/alrusov/misc"
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+type (
+	// ListenerTLS --
+	ListenerTLS struct {
+		CertFile string `toml:"cert-file" path:"config-relative"`
+		KeyFile  string `toml:"key-file" path:"config-relative"`
+
+		// Additional certificates selected by SNI
+		Certificates []ListenerTLSCert `toml:"certificates"`
+
+		// CA bundles for the client certificates verification (mutual TLS)
+		ClientCAFiles []string `toml:"client-ca-files" path:"config-relative"`
+
+		// none, request, require-any, verify-if-given, require-and-verify
+		ClientAuth string `toml:"client-auth"`
+
+		// 1.0, 1.1, 1.2, 1.3
+		MinVersion string `toml:"min-version"`
+
+		// Names as in crypto/tls, for example TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Not used for TLS 1.3
+		CipherSuites []string `toml:"cipher-suites"`
+
+		// A warning is written to the log if a certificate expires earlier
+		ExpiryWarning Duration `toml:"expiry-warning"`
+	}
+
+	// ListenerTLSCert --
+	ListenerTLSCert struct {
+		CertFile string `toml:"cert-file" path:"config-relative"`
+		KeyFile  string `toml:"key-file" path:"config-relative"` // may be empty if the key is in the CertFile
+	}
+)
+
+const (
+	// TLSDefaultMinVersion --
+	TLSDefaultMinVersion = "1.2"
+
+	// TLSDefaultExpiryWarning --
+	TLSDefaultExpiryWarning = Duration(30 * 24 * time.Hour)
+)
+
+var (
+	tlsVersions = map[string]uint16{
+		"1.0": tls.VersionTLS10,
+		"1.1": tls.VersionTLS11,
+		"1.2": tls.VersionTLS12,
+		"1.3": tls.VersionTLS13,
+	}
+
+	tlsClientAuthTypes = map[string]tls.ClientAuthType{
+		"none":               tls.NoClientCert,
+		"request":            tls.RequestClientCert,
+		"require-any":        tls.RequireAnyClientCert,
+		"verify-if-given":    tls.VerifyClientCertIfGiven,
+		"require-and-verify": tls.RequireAndVerifyClientCert,
+	}
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// IsTLS -- HTTPS is configured
+func (x *Listener) IsTLS() bool {
+	return x.SSLCombinedPem != "" || (x.TLS != nil && (x.TLS.CertFile != "" || len(x.TLS.Certificates) != 0))
+}
+
+// TLSConfig -- *tls.Config built from SSLCombinedPem and the TLS block. nil if TLS is not configured
+func (x *Listener) TLSConfig() (*tls.Config, error) {
+	if !x.IsTLS() {
+		return nil, nil
+	}
+
+	if x.tlsConfig == nil {
+		err := x.checkTLS()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return x.tlsConfig.Clone(), nil
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// checkTLS -- load and verify certificates, prepare tlsConfig
+func (x *Listener) checkTLS() (err error) {
+	msgs := misc.NewMessages()
+	defer msgs.Free()
+
+	x.tlsConfig = nil
+
+	if !x.IsTLS() {
+		return
+	}
+
+	tlsCfg := x.TLS
+	if tlsCfg == nil {
+		tlsCfg = &ListenerTLS{}
+	}
+
+	if tlsCfg.MinVersion == "" {
+		tlsCfg.MinVersion = TLSDefaultMinVersion
+	}
+
+	if tlsCfg.ExpiryWarning <= 0 {
+		tlsCfg.ExpiryWarning = TLSDefaultExpiryWarning
+	}
+
+	if tlsCfg.ClientAuth == "" {
+		tlsCfg.ClientAuth = "none"
+	}
+
+	cfg := &tls.Config{
+		Certificates: make([]tls.Certificate, 0, 1+len(tlsCfg.Certificates)),
+	}
+
+	loadCert := func(name string, certFile *string, keyFile *string) {
+		*certFile, err = misc.AbsPath(*certFile)
+		if err != nil {
+			msgs.Add("%s.cert-file: %s", name, err)
+			return
+		}
+
+		if *keyFile == "" {
+			*keyFile = *certFile
+		} else {
+			*keyFile, err = misc.AbsPath(*keyFile)
+			if err != nil {
+				msgs.Add("%s.key-file: %s", name, err)
+				return
+			}
+		}
+
+		cert, err := loadCertificate(*certFile, *keyFile, tlsCfg.ExpiryWarning.D())
+		if err != nil {
+			msgs.Add("%s: %s", name, err)
+			return
+		}
+
+		cfg.Certificates = append(cfg.Certificates, cert)
+	}
+
+	if x.SSLCombinedPem != "" {
+		keyFile := ""
+		loadCert("listener.ssl-combined-pem", &x.SSLCombinedPem, &keyFile)
+	}
+
+	if tlsCfg.CertFile != "" {
+		loadCert("listener.tls", &tlsCfg.CertFile, &tlsCfg.KeyFile)
+	} else if tlsCfg.KeyFile != "" {
+		msgs.Add("listener.tls: key-file is defined without cert-file")
+	}
+
+	for i := range tlsCfg.Certificates {
+		c := &tlsCfg.Certificates[i]
+		name := fmt.Sprintf("listener.tls.certificates[%d]", i)
+		if c.CertFile == "" {
+			msgs.Add("%s: empty cert-file", name)
+			continue
+		}
+		loadCert(name, &c.CertFile, &c.KeyFile)
+	}
+
+	if len(tlsCfg.ClientCAFiles) != 0 {
+		cfg.ClientCAs = x509.NewCertPool()
+
+		for i, fn := range tlsCfg.ClientCAFiles {
+			fn, err = misc.AbsPath(fn)
+			if err != nil {
+				msgs.Add("listener.tls.client-ca-files[%d]: %s", i, err)
+				continue
+			}
+			tlsCfg.ClientCAFiles[i] = fn
+
+			pem, err := os.ReadFile(fn)
+			if err != nil {
+				msgs.Add("listener.tls.client-ca-files[%d]: %s", i, err)
+				continue
+			}
+
+			if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+				msgs.Add(`listener.tls.client-ca-files[%d]: no certificates found in "%s"`, i, fn)
+			}
+		}
+	}
+
+	clientAuth, exists := tlsClientAuthTypes[strings.ToLower(tlsCfg.ClientAuth)]
+	if !exists {
+		msgs.Add(`listener.tls.client-auth: unknown value "%s"`, tlsCfg.ClientAuth)
+	} else {
+		cfg.ClientAuth = clientAuth
+		if (clientAuth == tls.VerifyClientCertIfGiven || clientAuth == tls.RequireAndVerifyClientCert) && cfg.ClientCAs == nil {
+			msgs.Add(`listener.tls.client-auth: "%s" requires client-ca-files`, tlsCfg.ClientAuth)
+		}
+	}
+
+	minVersion, exists := tlsVersions[tlsCfg.MinVersion]
+	if !exists {
+		msgs.Add(`listener.tls.min-version: unknown value "%s"`, tlsCfg.MinVersion)
+	} else {
+		cfg.MinVersion = minVersion
+	}
+
+	if len(tlsCfg.CipherSuites) != 0 {
+		known := make(map[string]*tls.CipherSuite, 64)
+		for _, cs := range tls.CipherSuites() {
+			known[cs.Name] = cs
+		}
+		for _, cs := range tls.InsecureCipherSuites() {
+			known[cs.Name] = cs
+		}
+
+		cfg.CipherSuites = make([]uint16, 0, len(tlsCfg.CipherSuites))
+
+		for _, name := range tlsCfg.CipherSuites {
+			cs, exists := known[strings.TrimSpace(name)]
+			if !exists {
+				msgs.Add(`listener.tls.cipher-suites: unknown cipher suite "%s"`, name)
+				continue
+			}
+			if cs.Insecure {
+				msgs.Add(`listener.tls.cipher-suites: cipher suite "%s" is insecure`, name)
+				continue
+			}
+			cfg.CipherSuites = append(cfg.CipherSuites, cs.ID)
+		}
+	}
+
+	err = msgs.Error()
+	if err != nil {
+		return
+	}
+
+	x.tlsConfig = cfg
+
+	return
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// loadCertificate -- load the key pair and verify the validity period
+func loadCertificate(certFile string, keyFile string, expiryWarning time.Duration) (cert tls.Certificate, err error) {
+	// It also checks that the private key matches the certificate
+	cert, err = tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return
+	}
+
+	leaf := cert.Leaf
+	if leaf == nil {
+		leaf, err = x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return
+		}
+		cert.Leaf = leaf
+	}
+
+	now := time.Now()
+
+	if now.Before(leaf.NotBefore) {
+		err = fmt.Errorf(`certificate "%s" is not valid before %s`, certFile, leaf.NotBefore.UTC().Format(time.RFC3339))
+		return
+	}
+
+	if now.After(leaf.NotAfter) {
+		err = fmt.Errorf(`certificate "%s" expired at %s`, certFile, leaf.NotAfter.UTC().Format(time.RFC3339))
+		return
+	}
+
+	if now.Add(expiryWarning).After(leaf.NotAfter) {
+		log.Message(log.WARNING, `Certificate "%s" expires at %s`, certFile, leaf.NotAfter.UTC().Format(time.RFC3339))
+	}
+
+	return
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
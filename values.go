@@ -0,0 +1,386 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alrusov/misc"
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+type (
+	// ByteSize -- "512KiB", "10MB", "1.5GiB" or a number of bytes
+	ByteSize int64
+
+	// URL -- absolute URL
+	URL struct {
+		url.URL
+	}
+
+	// Regexp -- regular expression compiled at load
+	Regexp struct {
+		*regexp.Regexp
+	}
+
+	// CIDR -- "10.0.0.0/8", a single address is converted to /32 or /128
+	CIDR struct {
+		netip.Prefix
+	}
+
+	// IPPrefix --
+	IPPrefix = CIDR
+
+	// FileMode -- octal permission bits, "0640"
+	FileMode os.FileMode
+
+	// Location -- timezone name, "Europe/Moscow"
+	Location struct {
+		*time.Location
+	}
+
+	// HostPort -- host:port, named ports are resolved to numbers
+	HostPort string
+
+	// Path -- absolute path, a relative one is resolved against the directory of the config file
+	Path string
+)
+
+var (
+	byteSizeUnits = map[string]int64{
+		"":    1,
+		"b":   1,
+		"kb":  1000,
+		"mb":  1000 * 1000,
+		"gb":  1000 * 1000 * 1000,
+		"tb":  1000 * 1000 * 1000 * 1000,
+		"pb":  1000 * 1000 * 1000 * 1000 * 1000,
+		"kib": 1 << 10,
+		"mib": 1 << 20,
+		"gib": 1 << 30,
+		"tib": 1 << 40,
+		"pib": 1 << 50,
+	}
+
+	// the order is important for MarshalText: binary units are preferred
+	byteSizeMarshalUnits = []struct {
+		n string
+		v int64
+	}{
+		{"PiB", 1 << 50},
+		{"TiB", 1 << 40},
+		{"GiB", 1 << 30},
+		{"MiB", 1 << 20},
+		{"KiB", 1 << 10},
+		{"PB", 1000 * 1000 * 1000 * 1000 * 1000},
+		{"TB", 1000 * 1000 * 1000 * 1000},
+		{"GB", 1000 * 1000 * 1000},
+		{"MB", 1000 * 1000},
+		{"KB", 1000},
+	}
+
+	reByteSize = regexp.MustCompile(`^([+-]?\d+(?:\.\d+)?)\s*([a-zA-Z]*)$`)
+)
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (x *ByteSize) UnmarshalText(data []byte) error {
+	s := strings.ReplaceAll(strings.TrimSpace(string(data)), "_", "")
+	if s == "" {
+		*x = 0
+		return nil
+	}
+
+	m := reByteSize.FindStringSubmatch(s)
+	if m == nil {
+		return fmt.Errorf(`bad size "%s" (expected something like "512KiB", "10MB" or a number of bytes)`, data)
+	}
+
+	unit, exists := byteSizeUnits[strings.ToLower(m[2])]
+	if !exists {
+		return fmt.Errorf(`bad size "%s": unknown unit "%s"`, data, m[2])
+	}
+
+	n, err := strconv.ParseInt(m[1], 10, 64)
+	if err == nil {
+		if n != 0 && (n*unit)/n != unit {
+			return fmt.Errorf(`bad size "%s": out of range`, data)
+		}
+		*x = ByteSize(n * unit)
+		return nil
+	}
+
+	f, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return fmt.Errorf(`bad size "%s": %s`, data, err)
+	}
+
+	f *= float64(unit)
+	if f >= math.MaxInt64 || f < math.MinInt64 {
+		return fmt.Errorf(`bad size "%s": out of range`, data)
+	}
+
+	*x = ByteSize(math.Round(f))
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (x ByteSize) MarshalText() ([]byte, error) {
+	return []byte(x.String()), nil
+}
+
+// String -- the largest unit without a loss of precision
+func (x ByteSize) String() string {
+	if x != 0 {
+		for _, u := range byteSizeMarshalUnits {
+			if int64(x)%u.v == 0 {
+				return strconv.FormatInt(int64(x)/u.v, 10) + u.n
+			}
+		}
+	}
+
+	return strconv.FormatInt(int64(x), 10)
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (x *URL) UnmarshalText(data []byte) error {
+	s := strings.TrimSpace(string(data))
+	if s == "" {
+		x.URL = url.URL{}
+		return nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme == "" || (u.Host == "" && u.Opaque == "" && u.Path == "") {
+		return fmt.Errorf(`bad URL "%s": must be absolute`, s)
+	}
+
+	x.URL = *u
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (x URL) MarshalText() ([]byte, error) {
+	return []byte(x.URL.String()), nil
+}
+
+// IsEmpty --
+func (x *URL) IsEmpty() bool {
+	return x.URL == url.URL{}
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (x *Regexp) UnmarshalText(data []byte) (err error) {
+	if len(data) == 0 {
+		x.Regexp = nil
+		return nil
+	}
+
+	x.Regexp, err = regexp.Compile(string(data))
+	if err != nil {
+		return fmt.Errorf(`bad regexp "%s": %s`, data, err)
+	}
+
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (x Regexp) MarshalText() ([]byte, error) {
+	if x.Regexp == nil {
+		return []byte{}, nil
+	}
+
+	return []byte(x.Regexp.String()), nil
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// ParseCIDR -- "10.0.0.0/8" or a single address, the result is masked
+func ParseCIDR(s string) (prefix netip.Prefix, err error) {
+	s = strings.TrimSpace(s)
+
+	if strings.Contains(s, "/") {
+		prefix, err = netip.ParsePrefix(s)
+		if err != nil {
+			return
+		}
+	} else {
+		var addr netip.Addr
+		addr, err = netip.ParseAddr(s)
+		if err != nil {
+			return
+		}
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+
+	return prefix.Masked(), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (x *CIDR) UnmarshalText(data []byte) (err error) {
+	if len(strings.TrimSpace(string(data))) == 0 {
+		x.Prefix = netip.Prefix{}
+		return nil
+	}
+
+	x.Prefix, err = ParseCIDR(string(data))
+	return
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (x CIDR) MarshalText() ([]byte, error) {
+	if !x.Prefix.IsValid() {
+		return []byte{}, nil
+	}
+
+	return []byte(x.Prefix.String()), nil
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (x *FileMode) UnmarshalText(data []byte) error {
+	s := strings.ReplaceAll(strings.TrimSpace(string(data)), "_", "")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0o"), "0O")
+
+	n, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || n > 0o7777 {
+		return fmt.Errorf(`bad file mode "%s" (expected octal number like "0640")`, data)
+	}
+
+	*x = FileMode(n)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (x FileMode) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%04o", uint32(x))), nil
+}
+
+// M --
+func (x FileMode) M() os.FileMode {
+	return os.FileMode(x)
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (x *Location) UnmarshalText(data []byte) (err error) {
+	s := strings.TrimSpace(string(data))
+	if s == "" {
+		x.Location = nil
+		return nil
+	}
+
+	x.Location, err = time.LoadLocation(s)
+	return
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (x Location) MarshalText() ([]byte, error) {
+	if x.Location == nil {
+		return []byte{}, nil
+	}
+
+	return []byte(x.Location.String()), nil
+}
+
+// L -- the location, UTC if it is not defined
+func (x Location) L() *time.Location {
+	if x.Location == nil {
+		return time.UTC
+	}
+
+	return x.Location
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (x *HostPort) UnmarshalText(data []byte) error {
+	s := strings.TrimSpace(string(data))
+	if s == "" {
+		*x = ""
+		return nil
+	}
+
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return err
+	}
+
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		n, err = net.LookupPort("tcp", port)
+		if err != nil {
+			return fmt.Errorf(`unknown port "%s" in "%s"`, port, s)
+		}
+	}
+
+	if n < 0 || n > 65535 {
+		return fmt.Errorf(`port %d is out of range in "%s"`, n, s)
+	}
+
+	*x = HostPort(net.JoinHostPort(host, strconv.Itoa(n)))
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (x HostPort) MarshalText() ([]byte, error) {
+	return []byte(x), nil
+}
+
+// Host --
+func (x HostPort) Host() string {
+	host, _, _ := net.SplitHostPort(string(x))
+	return host
+}
+
+// Port --
+func (x HostPort) Port() int {
+	_, port, _ := net.SplitHostPort(string(x))
+	n, _ := strconv.Atoi(port)
+	return n
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//
+
+// UnmarshalText implements encoding.TextUnmarshaler. The @, $ and ^ prefixes work as in misc.AbsPathEx
+func (x *Path) UnmarshalText(data []byte) error {
+	base := configDir
+	if base == "" {
+		base = misc.AppWorkDir()
+	}
+
+	s, err := configRelativePath(string(data), base)
+	if err != nil {
+		return err
+	}
+
+	*x = Path(s)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (x Path) MarshalText() ([]byte, error) {
+	return []byte(x), nil
+}
+
+//----------------------------------------------------------------------------------------------------------------------------//